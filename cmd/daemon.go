@@ -11,6 +11,8 @@ import (
 	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
 	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
 	"github.com/Cubicroots-Playground/trollinfo/internal/shiftnotifier"
+	"github.com/Cubicroots-Playground/trollinfo/internal/sink"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -18,23 +20,47 @@ func main() {
 	angelConfig := angelapi.Config{}
 	angelConfig.ParseFromEnvironment()
 
+	cacheConfig := angelapi.CacheConfig{}
+	cacheConfig.ParseFromEnvironment()
+
 	matrixConfig := matrixmessenger.Config{}
 	matrixConfig.ParseFromEnvironment()
 
 	notifierConfig := shiftnotifier.Config{}
 	notifierConfig.ParseFromEnvironment()
 
-	angelService := angelapi.New(&angelConfig)
+	angelService := angelapi.NewCache(angelapi.New(&angelConfig), &cacheConfig)
 	messenger, err := matrixmessenger.NewMessenger(
 		&matrixConfig, gologger.New(gologger.LogLevelDebug, 0),
 	)
 	if err != nil {
 		panic(err)
 	}
+	defer messenger.Close()
+
+	storeConfig := store.Config{}
+	storeConfig.ParseFromEnvironment()
+
+	snapshotStore, err := store.New(&storeConfig)
+	if err != nil {
+		panic(err)
+	}
+	defer snapshotStore.Close()
+
+	sinkConfig := sink.Config{}
+	sinkConfig.ParseFromEnvironment()
+
+	sinks, err := sink.New(&sinkConfig, messenger)
+	if err != nil {
+		panic(err)
+	}
+
+	shiftNotifier := shiftnotifier.New(&notifierConfig, angelService, messenger, sinks, snapshotStore)
 
-	shiftNotifier := shiftnotifier.New(&notifierConfig, angelService, messenger)
+	listenCtx, cancelListen := context.WithCancel(context.Background())
+	defer cancelListen()
 
-	eg, ctx := errgroup.WithContext(context.Background())
+	eg, ctx := errgroup.WithContext(listenCtx)
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 
@@ -50,9 +76,13 @@ func main() {
 		if err != nil {
 			slog.Error("failed stopping notifier", "error", err.Error())
 		}
+		cancelListen()
 	}()
 
 	eg.Go(shiftNotifier.Start)
+	eg.Go(func() error {
+		return messenger.Listen(listenCtx)
+	})
 	err = eg.Wait()
 	if err != nil {
 		slog.Error("error group failed", "error", err.Error())
@@ -0,0 +1,70 @@
+// Command fakeengelsystem serves recorded Engelsystem responses for demos and
+// local development, or records them from a real Engelsystem.
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/fakeengelsystem"
+)
+
+func main() {
+	listenAddr := flag.String("listen", ":8081", "address to serve the fake API at")
+	fixtures := flag.String("fixtures", "", "fixture directory, defaults to the built-in demo event")
+	record := flag.Bool("record", false, "record fixtures from TROLLINFO_API_BASE_URL into the fixture directory instead of serving")
+	locations := flag.String("locations", "", "comma separated location IDs to record, defaults to all")
+	now := flag.String("now", "", "fixed RFC 3339 time to serve the fixtures for, defaults to the current time")
+	flag.Parse()
+
+	if *record {
+		config := &fakeengelsystem.RecordConfig{
+			BaseURL: os.Getenv("TROLLINFO_API_BASE_URL"),
+			APIKey:  os.Getenv("TROLLINFO_API_KEY"),
+			Dir:     *fixtures,
+		}
+		for _, location := range strings.Split(*locations, ",") {
+			if location == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(location, 10, 64)
+			if err != nil {
+				panic(err)
+			}
+			config.LocationIDs = append(config.LocationIDs, id)
+		}
+
+		err := fakeengelsystem.Record(context.Background(), config)
+		if err != nil {
+			panic(err)
+		}
+		slog.Info("recorded fixtures", "dir", *fixtures)
+		return
+	}
+
+	config := &fakeengelsystem.Config{
+		APIKey: os.Getenv("TROLLINFO_API_KEY"),
+	}
+	if *fixtures != "" {
+		config.Fixtures = os.DirFS(*fixtures)
+	}
+	if *now != "" {
+		fixedTime, err := time.Parse(time.RFC3339, *now)
+		if err != nil {
+			panic(err)
+		}
+		config.Clock = func() time.Time { return fixedTime }
+	}
+
+	slog.Info("serving fake Engelsystem", "addr", *listenAddr)
+	err := http.ListenAndServe(*listenAddr, fakeengelsystem.New(config))
+	if err != nil {
+		panic(err)
+	}
+}
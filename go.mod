@@ -7,6 +7,8 @@ require (
 	github.com/dchest/uniuri v1.2.0
 	github.com/go-co-op/gocron/v2 v2.5.0
 	github.com/golang/mock v1.6.0
+	github.com/mattn/go-sqlite3 v1.14.22
+	go.etcd.io/bbolt v1.3.10
 	golang.org/x/sync v0.7.0
 	maunium.net/go/mautrix v0.18.1
 )
@@ -0,0 +1,21 @@
+package angelapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+func (service *service) ListAngelTypes(ctx context.Context, _ *ListAngelTypesOpts) ([]AngelType, error) {
+	response := map[string][]AngelType{}
+	err := service.makeRequest(ctx, http.MethodGet, "/api/v0-beta/angeltypes", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response["data"], nil
+}
+
+func (service *service) ListShiftsByAngelType(ctx context.Context, angelTypeID int64, opts *ListShiftsByAngelTypeOpts) ([]Shift, error) {
+	return service.listShifts(ctx, "/api/v0-beta/angeltypes/"+strconv.Itoa(int(angelTypeID))+"/shifts", opts)
+}
@@ -0,0 +1,264 @@
+package angelapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// CacheConfig holds the configuration for the caching service.
+type CacheConfig struct {
+	// LocationsTTL applies to rarely changing data like locations and types.
+	LocationsTTL time.Duration
+	// ShiftsTTL applies to shift lists and users.
+	ShiftsTTL time.Duration
+	// MaxStale limits how old cached data may be when it is served because
+	// the upstream is down.
+	MaxStale time.Duration
+}
+
+// ParseFromEnvironment parses the config from the environment.
+func (c *CacheConfig) ParseFromEnvironment() {
+	c.LocationsTTL = durationFromEnvironment("TROLLINFO_API_CACHE_LOCATIONS_TTL", time.Hour)
+	c.ShiftsTTL = durationFromEnvironment("TROLLINFO_API_CACHE_SHIFTS_TTL", time.Minute)
+	c.MaxStale = durationFromEnvironment("TROLLINFO_API_CACHE_MAX_STALE", time.Hour)
+}
+
+// Freshness reports whether a cached service served stale data. Attach it to
+// a context with WithFreshness before calling the service.
+type Freshness struct {
+	stale     bool
+	fetchedAt time.Time
+	mutex     sync.Mutex
+}
+
+type freshnessKey struct{}
+
+// WithFreshness returns a context recording the freshness of all data served
+// with it into freshness.
+func WithFreshness(ctx context.Context, freshness *Freshness) context.Context {
+	return context.WithValue(ctx, freshnessKey{}, freshness)
+}
+
+// Stale returns true if any of the served data is stale.
+func (freshness *Freshness) Stale() bool {
+	freshness.mutex.Lock()
+	defer freshness.mutex.Unlock()
+
+	return freshness.stale
+}
+
+// FetchedAt returns when the oldest served data was fetched from upstream.
+func (freshness *Freshness) FetchedAt() time.Time {
+	freshness.mutex.Lock()
+	defer freshness.mutex.Unlock()
+
+	return freshness.fetchedAt
+}
+
+func recordFreshness(ctx context.Context, stale bool, fetchedAt time.Time) {
+	freshness, ok := ctx.Value(freshnessKey{}).(*Freshness)
+	if !ok || freshness == nil {
+		return
+	}
+
+	freshness.mutex.Lock()
+	defer freshness.mutex.Unlock()
+
+	freshness.stale = freshness.stale || stale
+	if freshness.fetchedAt.IsZero() || fetchedAt.Before(freshness.fetchedAt) {
+		freshness.fetchedAt = fetchedAt
+	}
+}
+
+type cacheService struct {
+	upstream Service
+	config   *CacheConfig
+	entries  map[string]*cacheEntry
+	mutex    sync.Mutex
+}
+
+type cacheEntry struct {
+	value     any
+	filter    ShiftFilter
+	fetchedAt time.Time
+}
+
+// NewCache wraps the given service with a cache.
+func NewCache(upstream Service, config *CacheConfig) Service {
+	return &cacheService{
+		upstream: upstream,
+		config:   config,
+		entries:  make(map[string]*cacheEntry),
+	}
+}
+
+func (service *cacheService) ListLocations(ctx context.Context, opts *ListLocationsOpts) ([]Location, error) {
+	return cached(ctx, service, "locations", service.config.LocationsTTL, func() ([]Location, error) {
+		return service.upstream.ListLocations(ctx, opts)
+	})
+}
+
+func (service *cacheService) ListAngelTypes(ctx context.Context, opts *ListAngelTypesOpts) ([]AngelType, error) {
+	return cached(ctx, service, "angeltypes", service.config.LocationsTTL, func() ([]AngelType, error) {
+		return service.upstream.ListAngelTypes(ctx, opts)
+	})
+}
+
+func (service *cacheService) ListShiftTypes(ctx context.Context, opts *ListShiftTypesOpts) ([]ShiftType, error) {
+	return cached(ctx, service, "shifttypes", service.config.LocationsTTL, func() ([]ShiftType, error) {
+		return service.upstream.ListShiftTypes(ctx, opts)
+	})
+}
+
+func (service *cacheService) GetUser(ctx context.Context, userID int64) (*User, error) {
+	return cached(ctx, service, fmt.Sprintf("user:%d", userID), service.config.ShiftsTTL, func() (*User, error) {
+		return service.upstream.GetUser(ctx, userID)
+	})
+}
+
+func (service *cacheService) ListShiftsInLocation(ctx context.Context, locationID int64, opts *ListShiftsInLocationOpts) ([]Shift, error) {
+	return service.cachedShifts(ctx, fmt.Sprintf("location:%d", locationID), opts, func(filter *ShiftFilter) ([]Shift, error) {
+		return service.upstream.ListShiftsInLocation(ctx, locationID, filter)
+	})
+}
+
+func (service *cacheService) ListShiftsByAngelType(ctx context.Context, angelTypeID int64, opts *ListShiftsByAngelTypeOpts) ([]Shift, error) {
+	return service.cachedShifts(ctx, fmt.Sprintf("angeltype:%d", angelTypeID), opts, func(filter *ShiftFilter) ([]Shift, error) {
+		return service.upstream.ListShiftsByAngelType(ctx, angelTypeID, filter)
+	})
+}
+
+func (service *cacheService) ListUserShifts(ctx context.Context, userID int64, opts *ListUserShiftsOpts) ([]Shift, error) {
+	return service.cachedShifts(ctx, fmt.Sprintf("usershifts:%d", userID), opts, func(filter *ShiftFilter) ([]Shift, error) {
+		return service.upstream.ListUserShifts(ctx, userID, filter)
+	})
+}
+
+// cachedShifts caches shift lists per time window. Windows are widened to
+// full hours so successive calls with slightly moved windows hit the cache.
+func (service *cacheService) cachedShifts(ctx context.Context, key string, filter *ShiftFilter, fetch func(*ShiftFilter) ([]Shift, error)) ([]Shift, error) {
+	if filter == nil {
+		filter = &ShiftFilter{}
+	}
+
+	// The time window is the only filter applied after caching.
+	key = fmt.Sprintf("%s:%v:%t", key, filter.AngelTypeIDs, filter.OnlyWithEntries)
+	wideFilter := *filter
+	if !wideFilter.From.IsZero() {
+		wideFilter.From = wideFilter.From.Truncate(time.Hour)
+	}
+	if !wideFilter.Until.IsZero() {
+		wideFilter.Until = wideFilter.Until.Truncate(time.Hour).Add(time.Hour * 2)
+	}
+
+	service.mutex.Lock()
+	entry, ok := service.entries[key]
+	service.mutex.Unlock()
+	if ok && !entry.filter.covers(filter) {
+		ok = false
+	}
+
+	if ok && time.Since(entry.fetchedAt) < service.config.ShiftsTTL {
+		recordFreshness(ctx, false, entry.fetchedAt)
+		return cloneShifts(filter.filter(entry.value.([]Shift))), nil
+	}
+
+	shifts, err := fetch(&wideFilter)
+	if err != nil {
+		if ok && service.canServeStale(entry, err) {
+			slog.Warn("upstream failed, serving stale shifts", "key", key, "fetched_at", entry.fetchedAt, "error", err.Error())
+			recordFreshness(ctx, true, entry.fetchedAt)
+			return cloneShifts(filter.filter(entry.value.([]Shift))), nil
+		}
+		return nil, err
+	}
+
+	entry = &cacheEntry{
+		value:     shifts,
+		filter:    wideFilter,
+		fetchedAt: time.Now(),
+	}
+	service.mutex.Lock()
+	service.entries[key] = entry
+	service.mutex.Unlock()
+
+	recordFreshness(ctx, false, entry.fetchedAt)
+	return cloneShifts(filter.filter(shifts)), nil
+}
+
+// cloneShifts deep copies the shifts so callers cannot modify cached entries.
+func cloneShifts(shifts []Shift) []Shift {
+	cloned := slices.Clone(shifts)
+	for i := range cloned {
+		cloned[i].Entries = slices.Clone(cloned[i].Entries)
+		for j := range cloned[i].Entries {
+			cloned[i].Entries[j].Users = slices.Clone(cloned[i].Entries[j].Users)
+		}
+	}
+
+	return cloned
+}
+
+func cached[T any](ctx context.Context, service *cacheService, key string, ttl time.Duration, fetch func() (T, error)) (T, error) {
+	service.mutex.Lock()
+	entry, ok := service.entries[key]
+	service.mutex.Unlock()
+
+	if ok && time.Since(entry.fetchedAt) < ttl {
+		recordFreshness(ctx, false, entry.fetchedAt)
+		return entry.value.(T), nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		if ok && service.canServeStale(entry, err) {
+			slog.Warn("upstream failed, serving stale data", "key", key, "fetched_at", entry.fetchedAt, "error", err.Error())
+			recordFreshness(ctx, true, entry.fetchedAt)
+			return entry.value.(T), nil
+		}
+		return value, err
+	}
+
+	entry = &cacheEntry{
+		value:     value,
+		fetchedAt: time.Now(),
+	}
+	service.mutex.Lock()
+	service.entries[key] = entry
+	service.mutex.Unlock()
+
+	recordFreshness(ctx, false, entry.fetchedAt)
+	return value, nil
+}
+
+// canServeStale returns true if the upstream is down and the entry is still
+// young enough to be served.
+func (service *cacheService) canServeStale(entry *cacheEntry, err error) bool {
+	if time.Since(entry.fetchedAt) > service.config.MaxStale {
+		return false
+	}
+
+	var netErr *networkError
+	return errors.As(err, &netErr) ||
+		errors.Is(err, ErrServerUnavailable) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// covers returns true if all shifts matching other are included in data
+// fetched with filter.
+func (filter *ShiftFilter) covers(other *ShiftFilter) bool {
+	if !filter.From.IsZero() && (other.From.IsZero() || other.From.Before(filter.From)) {
+		return false
+	}
+	if !filter.Until.IsZero() && (other.Until.IsZero() || other.Until.After(filter.Until)) {
+		return false
+	}
+
+	return true
+}
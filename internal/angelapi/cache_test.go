@@ -0,0 +1,197 @@
+package angelapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeUpstream serves shifts from a function and counts the calls, other
+// methods are not implemented.
+type fakeUpstream struct {
+	Service
+	listShifts func(*ShiftFilter) ([]Shift, error)
+	filters    []ShiftFilter
+}
+
+func (upstream *fakeUpstream) ListShiftsInLocation(_ context.Context, _ int64, filter *ShiftFilter) ([]Shift, error) {
+	upstream.filters = append(upstream.filters, *filter)
+	return upstream.listShifts(filter)
+}
+
+var testCachedShift = Shift{
+	ID:       101,
+	StartsAt: time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC),
+	EndsAt:   time.Date(2024, 5, 31, 20, 0, 0, 0, time.UTC),
+	Entries: []ShiftEntry{{
+		Type:  ShiftType{ID: 3, Name: "Bar-Theke"},
+		Needs: 2,
+		Users: []User{{ID: 1, NickName: "Troll 1"}},
+	}},
+}
+
+func newTestCache(upstream *fakeUpstream) *cacheService {
+	return NewCache(upstream, &CacheConfig{
+		LocationsTTL: time.Hour,
+		ShiftsTTL:    time.Minute,
+		MaxStale:     time.Hour,
+	}).(*cacheService)
+}
+
+func TestCachedShiftsReturnsCopies(t *testing.T) {
+	upstream := &fakeUpstream{listShifts: func(*ShiftFilter) ([]Shift, error) {
+		return []Shift{cloneShifts([]Shift{testCachedShift})[0]}, nil
+	}}
+	cache := newTestCache(upstream)
+
+	for i := 0; i < 2; i++ {
+		shifts, err := cache.ListShiftsInLocation(context.Background(), 1, nil)
+		if err != nil {
+			t.Fatalf("ListShiftsInLocation() error = %v", err)
+		}
+		if len(shifts) != 1 || shifts[0].Entries[0].Users[0].NickName != "Troll 1" || len(shifts[0].Entries) != 1 {
+			t.Fatalf("call %d returned modified shifts %+v", i, shifts)
+		}
+
+		shifts[0].Title = "modified"
+		shifts[0].Entries[0].Users[0].NickName = "modified"
+		shifts[0].Entries[0].Users = append(shifts[0].Entries[0].Users, User{ID: 2})
+		shifts[0].Entries = append(shifts[0].Entries, ShiftEntry{})
+	}
+
+	if len(upstream.filters) != 1 {
+		t.Errorf("upstream called %d times, want 1", len(upstream.filters))
+	}
+}
+
+func TestCachedShiftsWidensWindow(t *testing.T) {
+	upstream := &fakeUpstream{listShifts: func(*ShiftFilter) ([]Shift, error) {
+		return []Shift{testCachedShift}, nil
+	}}
+	cache := newTestCache(upstream)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 31, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		filter    *ShiftFilter
+		wantCalls int
+	}{
+		{name: "first call", filter: &ShiftFilter{From: at(18, 10), Until: at(18, 40)}, wantCalls: 1},
+		{name: "moved window", filter: &ShiftFilter{From: at(18, 20), Until: at(19, 50)}, wantCalls: 1},
+		{name: "window ending later", filter: &ShiftFilter{From: at(18, 20), Until: at(20, 10)}, wantCalls: 2},
+		{name: "window starting earlier", filter: &ShiftFilter{From: at(17, 50), Until: at(19, 0)}, wantCalls: 3},
+		{name: "other angel types", filter: &ShiftFilter{From: at(17, 50), Until: at(19, 0), AngelTypeIDs: []int64{3}}, wantCalls: 4},
+	}
+
+	for _, tt := range tests {
+		_, err := cache.ListShiftsInLocation(context.Background(), 1, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ListShiftsInLocation() error = %v", tt.name, err)
+		}
+		if len(upstream.filters) != tt.wantCalls {
+			t.Errorf("%s: upstream called %d times, want %d", tt.name, len(upstream.filters), tt.wantCalls)
+		}
+	}
+
+	// Windows are widened to whole hours with one hour of slack.
+	first := upstream.filters[0]
+	if !first.From.Equal(at(18, 0)) || !first.Until.Equal(at(20, 0)) {
+		t.Errorf("upstream window %v - %v, want 18:00 - 20:00", first.From, first.Until)
+	}
+}
+
+func TestCachedShiftsServesStale(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		age       time.Duration
+		wantStale bool
+	}{
+		{name: "network error", err: &networkError{err: errors.New("connection refused")}, age: time.Minute * 5, wantStale: true},
+		{name: "server unavailable", err: newAPIError(http.StatusBadGateway, nil, 0), age: time.Minute * 5, wantStale: true},
+		{name: "rate limited", err: newAPIError(http.StatusTooManyRequests, nil, time.Second), age: time.Minute * 5, wantStale: true},
+		{name: "timeout", err: context.DeadlineExceeded, age: time.Minute * 5, wantStale: true},
+		{name: "unauthorized", err: newAPIError(http.StatusUnauthorized, nil, 0), age: time.Minute * 5},
+		{name: "not found", err: newAPIError(http.StatusNotFound, nil, 0), age: time.Minute * 5},
+		{name: "too old", err: newAPIError(http.StatusBadGateway, nil, 0), age: time.Hour * 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upstreamErr error
+			upstream := &fakeUpstream{listShifts: func(*ShiftFilter) ([]Shift, error) {
+				if upstreamErr != nil {
+					return nil, upstreamErr
+				}
+				return []Shift{testCachedShift}, nil
+			}}
+			cache := newTestCache(upstream)
+
+			_, err := cache.ListShiftsInLocation(context.Background(), 1, nil)
+			if err != nil {
+				t.Fatalf("ListShiftsInLocation() error = %v", err)
+			}
+			fetchedAt := time.Now().Add(-tt.age)
+			for _, entry := range cache.entries {
+				entry.fetchedAt = fetchedAt
+			}
+
+			upstreamErr = tt.err
+			freshness := &Freshness{}
+			shifts, err := cache.ListShiftsInLocation(WithFreshness(context.Background(), freshness), 1, nil)
+			if !tt.wantStale {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("ListShiftsInLocation() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ListShiftsInLocation() error = %v, want stale shifts", err)
+			}
+			if len(shifts) != 1 || shifts[0].ID != testCachedShift.ID {
+				t.Errorf("ListShiftsInLocation() = %+v, want the cached shift", shifts)
+			}
+			if !freshness.Stale() || !freshness.FetchedAt().Equal(fetchedAt) {
+				t.Errorf("freshness stale = %t fetched at %v, want stale fetched at %v", freshness.Stale(), freshness.FetchedAt(), fetchedAt)
+			}
+		})
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	upstream := &fakeUpstream{listShifts: func(*ShiftFilter) ([]Shift, error) {
+		return []Shift{testCachedShift}, nil
+	}}
+	cache := newTestCache(upstream)
+
+	_, err := cache.ListShiftsInLocation(context.Background(), 1, nil)
+	if err != nil {
+		t.Fatalf("ListShiftsInLocation() error = %v", err)
+	}
+	older := time.Now().Add(-time.Second * 30)
+	for _, entry := range cache.entries {
+		entry.fetchedAt = older
+	}
+
+	// The freshness holds the oldest data served with the context.
+	freshness := &Freshness{}
+	ctx := WithFreshness(context.Background(), freshness)
+	for _, locationID := range []int64{1, 2} {
+		_, err := cache.ListShiftsInLocation(ctx, locationID, nil)
+		if err != nil {
+			t.Fatalf("ListShiftsInLocation() error = %v", err)
+		}
+	}
+
+	if freshness.Stale() {
+		t.Error("freshness is stale, want fresh")
+	}
+	if !freshness.FetchedAt().Equal(older) {
+		t.Errorf("freshness fetched at %v, want %v", freshness.FetchedAt(), older)
+	}
+}
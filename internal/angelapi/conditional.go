@@ -0,0 +1,105 @@
+package angelapi
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Shift URLs contain the requested time window, so most validators are not
+// used again. Unused ones are evicted and the number kept is bounded.
+const (
+	validatorMaxAge = time.Hour
+	maxValidators   = 256
+)
+
+// validatorCache remembers ETag and Last-Modified values of GET responses so
+// unchanged data can be requested conditionally.
+type validatorCache struct {
+	entries map[string]*validator
+	mutex   sync.Mutex
+}
+
+type validator struct {
+	etag         string
+	lastModified string
+	body         []byte
+	usedAt       time.Time
+}
+
+func newValidatorCache() *validatorCache {
+	return &validatorCache{
+		entries: make(map[string]*validator),
+	}
+}
+
+// get returns the validator for the given URL or nil if there is none.
+func (cache *validatorCache) get(method, url string) *validator {
+	if method != http.MethodGet {
+		return nil
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	validator, ok := cache.entries[url]
+	if !ok {
+		return nil
+	}
+	validator.usedAt = time.Now()
+
+	return validator
+}
+
+func (cache *validatorCache) set(url string, resp *http.Response, body []byte) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	now := time.Now()
+	cache.entries[url] = &validator{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		body:         body,
+		usedAt:       now,
+	}
+
+	cache.evict(now)
+}
+
+// evict removes unused validators and the least recently used ones above the
+// limit.
+func (cache *validatorCache) evict(now time.Time) {
+	for url, validator := range cache.entries {
+		if now.Sub(validator.usedAt) > validatorMaxAge {
+			delete(cache.entries, url)
+		}
+	}
+
+	for len(cache.entries) > maxValidators {
+		oldestURL := ""
+		for url, validator := range cache.entries {
+			if oldestURL == "" || validator.usedAt.Before(cache.entries[oldestURL].usedAt) {
+				oldestURL = url
+			}
+		}
+		delete(cache.entries, oldestURL)
+	}
+}
+
+// apply makes the request conditional.
+func (validator *validator) apply(req *http.Request) {
+	if validator == nil {
+		return
+	}
+
+	if validator.etag != "" {
+		req.Header.Set("If-None-Match", validator.etag)
+	}
+	if validator.lastModified != "" {
+		req.Header.Set("If-Modified-Since", validator.lastModified)
+	}
+}
+
+func hasValidator(resp *http.Response) bool {
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
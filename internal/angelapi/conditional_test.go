@@ -0,0 +1,37 @@
+package angelapi
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestValidatorCacheEviction(t *testing.T) {
+	cache := newValidatorCache()
+	resp := &http.Response{Header: http.Header{"Etag": []string{`"v1"`}}}
+
+	cache.set("/unused", resp, []byte("{}"))
+	cache.entries["/unused"].usedAt = time.Now().Add(-validatorMaxAge - time.Minute)
+
+	cache.set("/first", resp, []byte("{}"))
+	for i := 0; i < maxValidators; i++ {
+		cache.set("/shifts?start="+strconv.Itoa(i), resp, []byte("{}"))
+		if i == 0 {
+			cache.entries["/shifts?start=0"].usedAt = time.Now().Add(-time.Minute)
+		}
+	}
+
+	if len(cache.entries) != maxValidators {
+		t.Errorf("cache holds %d validators, want %d", len(cache.entries), maxValidators)
+	}
+	if cache.get(http.MethodGet, "/unused") != nil {
+		t.Error("unused validator should be evicted")
+	}
+	if cache.get(http.MethodGet, "/shifts?start=0") != nil {
+		t.Error("least recently used validator should be evicted")
+	}
+	if cache.get(http.MethodGet, "/first") == nil {
+		t.Error("newer validator should be kept")
+	}
+}
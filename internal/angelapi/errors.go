@@ -0,0 +1,73 @@
+package angelapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Errors returned by the angel service, use errors.As with APIError to get
+// details about the failed request.
+var (
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrNotFound          = errors.New("not found")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrServerUnavailable = errors.New("server unavailable")
+)
+
+// maxErrorBodyLength limits how much of a response body is kept in errors.
+const maxErrorBodyLength = 512
+
+// APIError is returned if the Engelsystem API answers with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	// Body holds the response body, truncated to a sane length.
+	Body string
+	// RetryAfter holds the delay requested by the server, only set for
+	// ErrRateLimited.
+	RetryAfter time.Duration
+
+	kind error
+}
+
+func newAPIError(statusCode int, body []byte, retryAfter time.Duration) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Body:       string(body),
+	}
+	if len(apiErr.Body) > maxErrorBodyLength {
+		apiErr.Body = apiErr.Body[:maxErrorBodyLength] + "..."
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		apiErr.kind = ErrUnauthorized
+	case statusCode == http.StatusNotFound:
+		apiErr.kind = ErrNotFound
+	case statusCode == http.StatusTooManyRequests:
+		apiErr.kind = ErrRateLimited
+		apiErr.RetryAfter = retryAfter
+	case statusCode >= 500:
+		apiErr.kind = ErrServerUnavailable
+	}
+
+	return apiErr
+}
+
+func (err *APIError) Error() string {
+	if err.kind == nil {
+		return fmt.Sprintf("request failed with status %d: %s", err.StatusCode, err.Body)
+	}
+
+	return fmt.Sprintf("request failed with status %d (%s): %s", err.StatusCode, err.kind.Error(), err.Body)
+}
+
+func (err *APIError) Unwrap() error {
+	return err.kind
+}
+
+// isTemporary returns true if the request might succeed on a later attempt.
+func (err *APIError) isTemporary() bool {
+	return errors.Is(err.kind, ErrRateLimited) || errors.Is(err.kind, ErrServerUnavailable)
+}
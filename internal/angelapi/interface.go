@@ -1,13 +1,19 @@
 package angelapi
 
-import "time"
-
-var timeFormatISO8601 = "2006-01-02T15:04:05.999Z"
+import (
+	"context"
+	"time"
+)
 
 // Service defines an angelapi service.
 type Service interface {
-	ListLocations(*ListLocationsOpts) ([]Location, error)
-	ListShiftsInLocation(int64, *ListShiftsInLocationOpts) ([]Shift, error)
+	ListLocations(context.Context, *ListLocationsOpts) ([]Location, error)
+	ListShiftsInLocation(context.Context, int64, *ListShiftsInLocationOpts) ([]Shift, error)
+	ListShiftsByAngelType(context.Context, int64, *ListShiftsByAngelTypeOpts) ([]Shift, error)
+	ListAngelTypes(context.Context, *ListAngelTypesOpts) ([]AngelType, error)
+	ListShiftTypes(context.Context, *ListShiftTypesOpts) ([]ShiftType, error)
+	GetUser(context.Context, int64) (*User, error)
+	ListUserShifts(context.Context, int64, *ListUserShiftsOpts) ([]Shift, error)
 }
 
 // ListLocationsOpts holds options for listing locations.
@@ -15,7 +21,33 @@ type ListLocationsOpts struct {
 }
 
 // ListShiftsInLocationOpts holds options for listing shifts in a location.
-type ListShiftsInLocationOpts struct {
+type ListShiftsInLocationOpts = ShiftFilter
+
+// ListShiftsByAngelTypeOpts holds options for listing shifts of an angel type.
+type ListShiftsByAngelTypeOpts = ShiftFilter
+
+// ListUserShiftsOpts holds options for listing shifts of a user.
+type ListUserShiftsOpts = ShiftFilter
+
+// ListAngelTypesOpts holds options for listing angel types.
+type ListAngelTypesOpts struct {
+}
+
+// ListShiftTypesOpts holds options for listing shift types.
+type ListShiftTypesOpts struct {
+}
+
+// ShiftFilter holds filters applied when listing shifts.
+// Zero values disable the respective filter.
+type ShiftFilter struct {
+	// From and Until limit the result to shifts overlapping the time window.
+	From  time.Time
+	Until time.Time
+	// AngelTypeIDs limits the shift entries to the given angel types. Shifts
+	// without any matching entry are dropped.
+	AngelTypeIDs []int64
+	// OnlyWithEntries drops shifts nobody signed up for.
+	OnlyWithEntries bool
 }
 
 // Location represents a location.
@@ -34,6 +66,7 @@ type Shift struct {
 	StartsAt    time.Time
 	EndsAt      time.Time
 	Entries     []ShiftEntry `json:"entries"`
+	Location    Location     `json:"location"`
 }
 
 // ShiftType represents a shift type.
@@ -43,6 +76,14 @@ type ShiftType struct {
 	Description string `json:"description"`
 }
 
+// AngelType represents an angel type.
+type AngelType struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Restricted  bool   `json:"restricted"`
+}
+
 // ShiftEntry represents a shift entry.
 type ShiftEntry struct {
 	Users []User    `json:"users"`
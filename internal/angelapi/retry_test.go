@@ -0,0 +1,200 @@
+package angelapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRetryTestService returns a service for a server answering with the
+// given status codes in order, the last one is repeated.
+func newRetryTestService(t *testing.T, config *Config, retryAfter string, statusCodes ...int) (*service, *atomic.Int32) {
+	t.Helper()
+
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempt := int(requests.Add(1)) - 1
+		statusCode := statusCodes[min(attempt, len(statusCodes)-1)]
+		if statusCode == http.StatusOK {
+			_, _ = w.Write([]byte(`{"data": []}`))
+			return
+		}
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(statusCode)
+	}))
+	t.Cleanup(server.Close)
+
+	config.BaseURL = server.URL
+	config.Timeout = time.Second * 5
+	return New(config).(*service), requests
+}
+
+func TestMakeRequestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		maxRetries   int
+		wantErr      error
+		wantRequests int32
+	}{
+		{name: "success", statusCodes: []int{http.StatusOK}, maxRetries: 3, wantRequests: 1},
+		{name: "recovers", statusCodes: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, maxRetries: 3, wantRequests: 3},
+		{name: "rate limited", statusCodes: []int{http.StatusTooManyRequests, http.StatusOK}, maxRetries: 3, wantRequests: 2},
+		{name: "gives up", statusCodes: []int{http.StatusServiceUnavailable}, maxRetries: 2, wantErr: ErrServerUnavailable, wantRequests: 3},
+		{name: "no retries", statusCodes: []int{http.StatusServiceUnavailable}, maxRetries: 0, wantErr: ErrServerUnavailable, wantRequests: 1},
+		{name: "not temporary", statusCodes: []int{http.StatusNotFound, http.StatusOK}, maxRetries: 3, wantErr: ErrNotFound, wantRequests: 1},
+		{name: "unauthorized", statusCodes: []int{http.StatusUnauthorized, http.StatusOK}, maxRetries: 3, wantErr: ErrUnauthorized, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, requests := newRetryTestService(t, &Config{
+				MaxRetries:     tt.maxRetries,
+				RetryBaseDelay: time.Millisecond,
+				RetryMaxDelay:  time.Millisecond * 5,
+			}, "", tt.statusCodes...)
+
+			err := service.makeRequest(context.Background(), http.MethodGet, "/api/v0-beta/locations", nil, &map[string]any{})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("makeRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if requests.Load() != tt.wantRequests {
+				t.Errorf("%d requests made, want %d", requests.Load(), tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestMakeRequestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name          string
+		retryAfter    string
+		maxDelay      time.Duration
+		timeout       time.Duration
+		wantErr       error
+		wantRequests  int32
+		wantMaxWait   time.Duration
+		wantMinWaited time.Duration
+	}{
+		{
+			name:          "honored",
+			retryAfter:    "1",
+			maxDelay:      time.Second * 5,
+			wantRequests:  2,
+			wantMinWaited: time.Second,
+			wantMaxWait:   time.Second * 3,
+		},
+		{
+			name:         "capped at max delay",
+			retryAfter:   "3600",
+			maxDelay:     time.Millisecond * 10,
+			wantRequests: 2,
+			wantMaxWait:  time.Second,
+		},
+		{
+			name:         "HTTP date capped at max delay",
+			retryAfter:   time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+			maxDelay:     time.Millisecond * 10,
+			wantRequests: 2,
+			wantMaxWait:  time.Second,
+		},
+		{
+			name:         "beyond deadline",
+			retryAfter:   "60",
+			maxDelay:     time.Minute * 5,
+			timeout:      time.Millisecond * 200,
+			wantErr:      ErrRateLimited,
+			wantRequests: 1,
+			wantMaxWait:  time.Millisecond * 150,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, requests := newRetryTestService(t, &Config{
+				MaxRetries:     3,
+				RetryBaseDelay: time.Millisecond,
+				RetryMaxDelay:  tt.maxDelay,
+			}, tt.retryAfter, http.StatusTooManyRequests, http.StatusOK)
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			err := service.makeRequest(ctx, http.MethodGet, "/api/v0-beta/locations", nil, &map[string]any{})
+			waited := time.Since(start)
+
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("makeRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if requests.Load() != tt.wantRequests {
+				t.Errorf("%d requests made, want %d", requests.Load(), tt.wantRequests)
+			}
+			if waited > tt.wantMaxWait || waited < tt.wantMinWaited {
+				t.Errorf("waited %v, want between %v and %v", waited, tt.wantMinWaited, tt.wantMaxWait)
+			}
+
+			var apiErr *APIError
+			if tt.wantErr != nil && errors.As(err, &apiErr) && apiErr.RetryAfter != time.Minute {
+				t.Errorf("RetryAfter = %v, want the requested delay", apiErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	service := &service{config: &Config{
+		RetryBaseDelay: time.Millisecond * 100,
+		RetryMaxDelay:  time.Second,
+	}}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Millisecond * 100},
+		{attempt: 1, want: time.Millisecond * 200},
+		{attempt: 3, want: time.Millisecond * 800},
+		{attempt: 4, want: time.Second},
+		{attempt: 100, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			delay := service.backoff(tt.attempt)
+			if delay < tt.want/2 || delay > tt.want {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", tt.attempt, delay, tt.want/2, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{value: "", min: 0, max: 0},
+		{value: "120", min: time.Minute * 2, max: time.Minute * 2},
+		{value: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: time.Second * 58, max: time.Minute},
+		{value: "soon", min: 0, max: 0},
+	}
+
+	for _, tt := range tests {
+		got := parseRetryAfter(tt.value)
+		if got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want between %v and %v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
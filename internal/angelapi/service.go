@@ -1,18 +1,34 @@
 package angelapi
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 )
 
 // Config holds the configuration for an angel service.
 type Config struct {
 	BaseURL string
 	APIKey  string
+	// TimeZone is used for times the API emits without zone information.
+	TimeZone *time.Location
+
+	// Timeout limits a single HTTP request including reading the body.
+	Timeout time.Duration
+	// MaxRetries is the amount of retries after the first attempt failed.
+	MaxRetries int
+	// RetryBaseDelay is the delay before the first retry, it doubles with
+	// every further attempt up to RetryMaxDelay.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
 }
 
 // ParseFromEnvironment parses the config from the environment.
@@ -21,50 +37,216 @@ func (c *Config) ParseFromEnvironment() {
 	apiKey := os.Getenv("TROLLINFO_API_KEY")
 	c.BaseURL = baseURL
 	c.APIKey = apiKey
+
+	timeZone := os.Getenv("TROLLINFO_API_TIMEZONE")
+	if timeZone == "" {
+		timeZone = "Europe/Berlin"
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		slog.Warn("invalid time zone, using UTC", "value", timeZone, "error", err.Error())
+		loc = time.UTC
+	}
+	c.TimeZone = loc
+
+	c.Timeout = durationFromEnvironment("TROLLINFO_API_TIMEOUT", time.Second*10)
+	c.MaxRetries = 3
+	if maxRetries := os.Getenv("TROLLINFO_API_MAX_RETRIES"); maxRetries != "" {
+		parsed, err := strconv.Atoi(maxRetries)
+		if err != nil {
+			slog.Warn("invalid max retries, using default", "value", maxRetries, "error", err.Error())
+		} else {
+			c.MaxRetries = parsed
+		}
+	}
+	c.RetryBaseDelay = time.Millisecond * 500
+	c.RetryMaxDelay = time.Second * 30
+}
+
+func durationFromEnvironment(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration, using default", "name", name, "value", value, "error", err.Error())
+		return fallback
+	}
+
+	return duration
 }
 
 type service struct {
-	config *Config
+	config     *Config
+	client     *http.Client
+	validators *validatorCache
 }
 
 // New assembles a new angel service.
 func New(config *Config) Service {
 	return &service{
 		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+		},
+		validators: newValidatorCache(),
+	}
+}
+
+func (service *service) makeRequest(ctx context.Context, method string, urlPath string, body io.Reader, parseResponseTo interface{}) error {
+	// Buffer the body so it can be sent again on retries.
+	var rawBody []byte
+	if body != nil {
+		var err error
+		rawBody, err = io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := service.doRequest(ctx, method, urlPath, rawBody, parseResponseTo)
+		if err == nil {
+			return nil
+		}
+
+		var netErr *networkError
+		var apiErr *APIError
+		var delay time.Duration
+		switch {
+		case errors.As(err, &netErr):
+		case errors.As(err, &apiErr) && apiErr.isTemporary():
+			delay = apiErr.RetryAfter
+		default:
+			return err
+		}
+
+		if attempt >= service.config.MaxRetries {
+			return err
+		}
+		if delay <= 0 {
+			delay = service.backoff(attempt)
+		}
+		// Servers may ask for long delays, do not block longer than
+		// configured or beyond the deadline of the caller.
+		if delay > service.config.RetryMaxDelay {
+			delay = service.config.RetryMaxDelay
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		slog.Warn("request failed, retrying", "attempt", attempt+1, "delay", delay, "error", err.Error())
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
 	}
 }
 
-func (service *service) makeRequest(method string, urlPath string, body io.Reader, parseResponseTo interface{}) error {
+// networkError marks errors where no response was received.
+type networkError struct {
+	err error
+}
+
+func (err *networkError) Error() string {
+	return err.err.Error()
+}
+
+func (err *networkError) Unwrap() error {
+	return err.err
+}
+
+// doRequest makes a single request.
+func (service *service) doRequest(ctx context.Context, method string, urlPath string, body []byte, parseResponseTo interface{}) error {
 	url := service.config.BaseURL + urlPath
 	slog.Info("making request", "method", method, "url", url)
 
-	req, err := http.NewRequest(method, url, body)
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("x-api-key", service.config.APIKey)
+	validator := service.validators.get(method, url)
+	validator.apply(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := service.client.Do(req)
 	if err != nil {
-		return err
+		if ctx.Err() != nil {
+			return err
+		}
+		return &networkError{err: err}
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && validator != nil {
+		return json.Unmarshal(validator.body, parseResponseTo)
+	}
+
 	if resp.StatusCode < 200 ||
 		resp.StatusCode > 299 {
-		rawBody, err := io.ReadAll(resp.Body)
+		rawBody, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLength+1))
 		if err != nil {
 			return err
 		}
 
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(rawBody))
+		return newAPIError(resp.StatusCode, rawBody, parseRetryAfter(resp.Header.Get("Retry-After")))
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(parseResponseTo)
+	if !hasValidator(resp) || method != http.MethodGet {
+		return json.NewDecoder(resp.Body).Decode(parseResponseTo)
+	}
+
+	rawBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &networkError{err: err}
+	}
+	err = json.Unmarshal(rawBody, parseResponseTo)
 	if err != nil {
 		return err
 	}
 
+	service.validators.set(url, resp, rawBody)
 	return nil
 }
+
+// backoff returns the exponential delay for the given attempt with jitter
+// applied to the upper half.
+func (service *service) backoff(attempt int) time.Duration {
+	delay := service.config.RetryBaseDelay << attempt
+	if delay <= 0 || delay > service.config.RetryMaxDelay {
+		delay = service.config.RetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or HTTP date format.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
@@ -0,0 +1,151 @@
+package angelapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/Cubicroots-Playground/trollinfo/internal/fakeengelsystem"
+)
+
+// recordedAt is the recording time of the default fixtures.
+var recordedAt = time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC)
+
+func newTestClient(t *testing.T, fakeConfig *fakeengelsystem.Config, apiKey string) angelapi.Service {
+	t.Helper()
+
+	server := httptest.NewServer(fakeengelsystem.New(fakeConfig))
+	t.Cleanup(server.Close)
+
+	return angelapi.New(&angelapi.Config{
+		BaseURL:        server.URL,
+		APIKey:         apiKey,
+		TimeZone:       time.UTC,
+		Timeout:        time.Second * 5,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  time.Millisecond,
+	})
+}
+
+func shiftIDs(shifts []angelapi.Shift) []int64 {
+	ids := make([]int64, 0, len(shifts))
+	for _, shift := range shifts {
+		ids = append(ids, shift.ID)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+func TestListLocations(t *testing.T) {
+	client := newTestClient(t, &fakeengelsystem.Config{}, "")
+
+	locations, err := client.ListLocations(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListLocations() error = %v", err)
+	}
+
+	want := []angelapi.Location{{ID: 1, Name: "Bar Nord"}, {ID: 2, Name: "Tschunk Bar"}}
+	if !slices.Equal(locations, want) {
+		t.Errorf("ListLocations() = %+v, want %+v", locations, want)
+	}
+}
+
+func TestListShiftsInLocation(t *testing.T) {
+	tests := []struct {
+		name       string
+		now        time.Time
+		locationID int64
+		from       time.Duration
+		until      time.Duration
+		wantIDs    []int64
+	}{
+		{
+			name:       "around a shift change",
+			now:        recordedAt,
+			locationID: 1,
+			from:       -time.Minute * 15,
+			until:      time.Minute,
+			wantIDs:    []int64{101, 102},
+		},
+		{
+			name:       "whole evening",
+			now:        recordedAt,
+			locationID: 2,
+			from:       -time.Hour,
+			until:      time.Hour * 5,
+			wantIDs:    []int64{201, 202, 203},
+		},
+		{
+			name:       "shift times follow the clock",
+			now:        recordedAt.Add(time.Hour * 48),
+			locationID: 1,
+			from:       time.Hour * 2,
+			until:      time.Hour * 3,
+			wantIDs:    []int64{103},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, &fakeengelsystem.Config{
+				Clock: func() time.Time { return tt.now },
+			}, "")
+
+			shifts, err := client.ListShiftsInLocation(context.Background(), tt.locationID, &angelapi.ListShiftsInLocationOpts{
+				From:  tt.now.Add(tt.from),
+				Until: tt.now.Add(tt.until),
+			})
+			if err != nil {
+				t.Fatalf("ListShiftsInLocation() error = %v", err)
+			}
+
+			if ids := shiftIDs(shifts); !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("ListShiftsInLocation() returned shifts %v, want %v", ids, tt.wantIDs)
+			}
+			for _, shift := range shifts {
+				if shift.StartsAt.IsZero() || !shift.EndsAt.After(shift.StartsAt) {
+					t.Errorf("shift %d has invalid times %v - %v", shift.ID, shift.StartsAt, shift.EndsAt)
+				}
+			}
+		})
+	}
+}
+
+func TestListShiftsInLocationErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		fakeAPIKey string
+		apiKey     string
+		locationID int64
+		wantErr    error
+	}{
+		{
+			name:       "wrong API key",
+			fakeAPIKey: "secret",
+			apiKey:     "wrong",
+			locationID: 1,
+			wantErr:    angelapi.ErrUnauthorized,
+		},
+		{
+			name:       "unknown location",
+			locationID: 99,
+			wantErr:    angelapi.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, &fakeengelsystem.Config{APIKey: tt.fakeAPIKey}, tt.apiKey)
+
+			_, err := client.ListShiftsInLocation(context.Background(), tt.locationID, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ListShiftsInLocation() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package angelapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+func (service *service) listShifts(ctx context.Context, urlPath string, filter *ShiftFilter) ([]Shift, error) {
+	if filter == nil {
+		filter = &ShiftFilter{}
+	}
+
+	if query := filter.query().Encode(); query != "" {
+		urlPath += "?" + query
+	}
+
+	response := map[string][]Shift{}
+	err := service.makeRequest(ctx, http.MethodGet, urlPath, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	shifts := make([]Shift, 0, len(response["data"]))
+	for _, shift := range response["data"] {
+		err := service.parseShiftTimes(&shift)
+		if err != nil {
+			// A shift without times would end up in the wrong lists, better
+			// drop it.
+			slog.Warn("dropping shift with invalid time", "url", urlPath, "error", err.Error())
+			continue
+		}
+
+		shifts = append(shifts, shift)
+	}
+
+	return filter.filter(shifts), nil
+}
+
+// query returns the filters the Engelsystem API understands as query parameters.
+func (filter *ShiftFilter) query() url.Values {
+	query := url.Values{}
+	if !filter.From.IsZero() {
+		query.Set("start", filter.From.UTC().Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		query.Set("end", filter.Until.UTC().Format(time.RFC3339))
+	}
+
+	return query
+}
+
+// filter applies all filters client-side. Older Engelsystem versions ignore the
+// query parameters, so the time window is checked here as well.
+func (filter *ShiftFilter) filter(shifts []Shift) []Shift {
+	filtered := make([]Shift, 0, len(shifts))
+	for _, shift := range shifts {
+		if !filter.From.IsZero() && !shift.EndsAt.After(filter.From) {
+			continue
+		}
+		if !filter.Until.IsZero() && !shift.StartsAt.Before(filter.Until) {
+			continue
+		}
+
+		if len(filter.AngelTypeIDs) > 0 {
+			entries := make([]ShiftEntry, 0, len(shift.Entries))
+			for _, entry := range shift.Entries {
+				if slices.Contains(filter.AngelTypeIDs, entry.Type.ID) {
+					entries = append(entries, entry)
+				}
+			}
+			if len(entries) == 0 {
+				continue
+			}
+			shift.Entries = entries
+		}
+
+		if filter.OnlyWithEntries && !shift.hasUsers() {
+			continue
+		}
+
+		filtered = append(filtered, shift)
+	}
+
+	return filtered
+}
+
+func (shift *Shift) hasUsers() bool {
+	for _, entry := range shift.Entries {
+		if len(entry.Users) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
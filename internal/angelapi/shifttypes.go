@@ -0,0 +1,16 @@
+package angelapi
+
+import (
+	"context"
+	"net/http"
+)
+
+func (service *service) ListShiftTypes(ctx context.Context, _ *ListShiftTypesOpts) ([]ShiftType, error) {
+	response := map[string][]ShiftType{}
+	err := service.makeRequest(ctx, http.MethodGet, "/api/v0-beta/shifttypes", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response["data"], nil
+}
@@ -0,0 +1,89 @@
+package angelapi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Layouts with zone information, RFC 3339 also accepts fractional seconds.
+var timeLayoutsWithZone = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+}
+
+// Layouts without zone information, interpreted in the configured time zone.
+var timeLayoutsWithoutZone = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// ErrInvalidTime is returned if a time emitted by the API can not be parsed.
+var ErrInvalidTime = errors.New("invalid time")
+
+// ShiftTimeError describes a shift with a start or end time that could not be
+// parsed.
+type ShiftTimeError struct {
+	ShiftID int64
+	Field   string
+	Value   string
+}
+
+func (err *ShiftTimeError) Error() string {
+	return fmt.Sprintf("shift %d has invalid %s %q", err.ShiftID, err.Field, err.Value)
+}
+
+func (err *ShiftTimeError) Unwrap() error {
+	return ErrInvalidTime
+}
+
+// parseShiftTimes sets StartsAt and EndsAt from their raw values.
+func (service *service) parseShiftTimes(shift *Shift) error {
+	startsAt, err := parseTime(shift.StartsAtRaw, service.config.TimeZone)
+	if err != nil {
+		return &ShiftTimeError{ShiftID: shift.ID, Field: "starts_at", Value: shift.StartsAtRaw}
+	}
+
+	endsAt, err := parseTime(shift.EndsAtRaw, service.config.TimeZone)
+	if err != nil {
+		return &ShiftTimeError{ShiftID: shift.ID, Field: "ends_at", Value: shift.EndsAtRaw}
+	}
+
+	if endsAt.Before(startsAt) {
+		return &ShiftTimeError{ShiftID: shift.ID, Field: "ends_at", Value: shift.EndsAtRaw}
+	}
+
+	shift.StartsAt = startsAt
+	shift.EndsAt = endsAt
+	return nil
+}
+
+// parseTime parses the time formats the Engelsystem API emits. Times without
+// zone information are interpreted in the given location.
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, ErrInvalidTime
+	}
+
+	for _, layout := range timeLayoutsWithZone {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range timeLayoutsWithoutZone {
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, ErrInvalidTime
+}
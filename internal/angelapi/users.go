@@ -0,0 +1,26 @@
+package angelapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+func (service *service) GetUser(ctx context.Context, userID int64) (*User, error) {
+	response := map[string]User{}
+	err := service.makeRequest(ctx, http.MethodGet, "/api/v0-beta/users/"+strconv.Itoa(int(userID)), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	user, ok := response["data"]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (service *service) ListUserShifts(ctx context.Context, userID int64, opts *ListUserShiftsOpts) ([]Shift, error) {
+	return service.listShifts(ctx, "/api/v0-beta/users/"+strconv.Itoa(int(userID))+"/shifts", opts)
+}
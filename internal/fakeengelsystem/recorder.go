@@ -0,0 +1,164 @@
+package fakeengelsystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RecordConfig holds the configuration for recording fixtures.
+type RecordConfig struct {
+	// BaseURL and APIKey of the real Engelsystem.
+	BaseURL string
+	APIKey  string
+	// Dir is the directory fixtures are written to.
+	Dir string
+	// LocationIDs to record shifts for, all locations if empty.
+	LocationIDs []int64
+}
+
+// Record captures responses of a real Engelsystem into fixtures the Server
+// can serve. Personal data is scrubbed before writing.
+//
+// Fixtures are laid out as:
+//
+//	meta.json           recording time
+//	locations.json      response of /api/v0-beta/locations
+//	shifts/{id}.json    response of /api/v0-beta/locations/{id}/shifts
+func Record(ctx context.Context, config *RecordConfig) error {
+	err := os.MkdirAll(filepath.Join(config.Dir, "shifts"), 0o755)
+	if err != nil {
+		return err
+	}
+
+	locations := map[string][]map[string]any{}
+	err = fetch(ctx, config, "/api/v0-beta/locations", &locations)
+	if err != nil {
+		return err
+	}
+	err = writeFixture(config.Dir, fixtureLocations, locations)
+	if err != nil {
+		return err
+	}
+
+	locationIDs := config.LocationIDs
+	if len(locationIDs) == 0 {
+		for _, location := range locations["data"] {
+			if id, ok := location["id"].(float64); ok {
+				locationIDs = append(locationIDs, int64(id))
+			}
+		}
+	}
+
+	scrubber := newScrubber()
+	for _, locationID := range locationIDs {
+		shifts := map[string]any{}
+		err = fetch(ctx, config, fmt.Sprintf("/api/v0-beta/locations/%d/shifts", locationID), &shifts)
+		if err != nil {
+			return err
+		}
+
+		scrubber.scrub(shifts)
+		err = writeFixture(config.Dir, fixtureShifts(locationID), shifts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFixture(config.Dir, fixtureMetaFile, fixtureMeta{RecordedAt: time.Now().UTC()})
+}
+
+func fetch(ctx context.Context, config *RecordConfig, urlPath string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.BaseURL+urlPath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", config.APIKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("recording %s failed with status %d: %s", urlPath, resp.StatusCode, string(body))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func writeFixture(dir string, name string, v any) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, name), raw, 0o644)
+}
+
+// scrubber replaces personal data of users with stable pseudonyms, the same
+// user gets the same pseudonym in all fixtures.
+type scrubber struct {
+	nicknames map[float64]string
+}
+
+func newScrubber() *scrubber {
+	return &scrubber{
+		nicknames: make(map[float64]string),
+	}
+}
+
+// scrub walks the response and scrubs all objects listed as users.
+func (scrubber *scrubber) scrub(v any) {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, child := range value {
+			if users, ok := child.([]any); ok && key == "users" {
+				for _, user := range users {
+					if user, ok := user.(map[string]any); ok {
+						scrubber.scrubUser(user)
+					}
+				}
+				continue
+			}
+			scrubber.scrub(child)
+		}
+	case []any:
+		for _, child := range value {
+			scrubber.scrub(child)
+		}
+	}
+}
+
+func (scrubber *scrubber) scrubUser(user map[string]any) {
+	id, _ := user["id"].(float64)
+	nickname, ok := scrubber.nicknames[id]
+	if !ok {
+		nickname = fmt.Sprintf("Troll %d", len(scrubber.nicknames)+1)
+		scrubber.nicknames[id] = nickname
+	}
+
+	for key := range user {
+		switch key {
+		case "id":
+		case "name":
+			user[key] = nickname
+		case "contact":
+			user[key] = map[string]any{}
+		default:
+			// Unknown fields might hold numbers or nested contact data too.
+			if _, ok := user[key].(string); ok {
+				user[key] = ""
+			} else {
+				delete(user, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package fakeengelsystem
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRecordedShifts = `{"data": [{
+	"id": 101,
+	"title": "Bar Nord Früh",
+	"starts_at": "2024-05-31T16:00:00Z",
+	"ends_at": "2024-05-31T18:00:00Z",
+	"location": {"id": %LOCATION%, "name": "Bar"},
+	"entries": [{
+		"type": {"id": 3, "name": "Bar-Theke"},
+		"needs": 2,
+		"users": [
+			{
+				"id": 42,
+				"name": "tschunkfan",
+				"first_name": "Erika",
+				"last_name": "Mustermann",
+				"pronoun": "sie",
+				"email": "erika@example.org",
+				"contact": {"dect": "4711", "mobile": "+49 170 1234567"},
+				"dect": 4712,
+				"phones": [{"mobile": "+49 171 7654321"}]
+			},
+			{
+				"id": 43,
+				"name": "mate-max",
+				"contact": {"dect": "0815", "email": "max@example.org"}
+			}
+		]
+	}]
+}]}`
+
+func TestRecordScrubsPersonalData(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/api/v0-beta/locations":
+			_, _ = w.Write([]byte(`{"data": [{"id": 1, "name": "Bar Nord"}, {"id": 2, "name": "Tschunk Bar"}]}`))
+		case "/api/v0-beta/locations/1/shifts":
+			_, _ = w.Write([]byte(strings.ReplaceAll(testRecordedShifts, "%LOCATION%", "1")))
+		case "/api/v0-beta/locations/2/shifts":
+			_, _ = w.Write([]byte(strings.ReplaceAll(testRecordedShifts, "%LOCATION%", "2")))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	dir := t.TempDir()
+	err := Record(context.Background(), &RecordConfig{
+		BaseURL: upstream.URL,
+		APIKey:  "secret",
+		Dir:     dir,
+	})
+	if err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	personalData := []string{
+		"tschunkfan", "mate-max",
+		"Erika", "Mustermann", "sie",
+		"erika@example.org", "max@example.org",
+		"+49 170 1234567", "+49 171 7654321",
+		"4711", "4712", "0815",
+	}
+	for _, name := range []string{fixtureShifts(1), fixtureShifts(2)} {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		for _, data := range personalData {
+			if strings.Contains(string(raw), data) {
+				t.Errorf("%s contains %q", name, data)
+			}
+		}
+
+		// Users keep their ID and get the same pseudonym in all fixtures.
+		shifts := map[string][]struct {
+			Entries []struct {
+				Users []map[string]any `json:"users"`
+			} `json:"entries"`
+		}{}
+		err = json.Unmarshal(raw, &shifts)
+		if err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		users := shifts["data"][0].Entries[0].Users
+		if users[0]["id"] != float64(42) || users[0]["name"] != "Troll 1" || users[1]["name"] != "Troll 2" {
+			t.Errorf("%s holds users %v, want pseudonyms Troll 1 and Troll 2", name, users)
+		}
+	}
+
+	for _, name := range []string{fixtureLocations, fixtureMetaFile} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("fixture %s missing: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package fakeengelsystem
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"embed"
+)
+
+// defaultFixtures holds a small demo event with two locations.
+//
+//go:embed fixtures
+var defaultFixtures embed.FS
+
+// DefaultFixtures returns the fixtures shipped with the binary.
+func DefaultFixtures() fs.FS {
+	fixtures, _ := fs.Sub(defaultFixtures, "fixtures")
+	return fixtures
+}
+
+// Config holds the configuration for the fake Engelsystem.
+type Config struct {
+	// Fixtures to serve, see Record for the layout.
+	Fixtures fs.FS
+	// Clock returns the current time. Shift times are moved so the
+	// recording appears to have happened in the current hour. Defaults to
+	// time.Now.
+	Clock func() time.Time
+	// APIKey is required as x-api-key header if set.
+	APIKey string
+}
+
+// Server is a fake Engelsystem API serving recorded fixtures.
+type Server struct {
+	config *Config
+	mux    *http.ServeMux
+}
+
+// New assembles a new fake Engelsystem server.
+func New(config *Config) *Server {
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	if config.Fixtures == nil {
+		config.Fixtures = DefaultFixtures()
+	}
+
+	server := &Server{
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+
+	server.mux.HandleFunc("GET /api/v0-beta/locations", server.serveLocations)
+	server.mux.HandleFunc("GET /api/v0-beta/locations/{id}/shifts", server.serveShifts)
+
+	return server
+}
+
+// ServeHTTP implements http.Handler.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if server.config.APIKey != "" && r.Header.Get("x-api-key") != server.config.APIKey {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+		return
+	}
+
+	server.mux.ServeHTTP(w, r)
+}
+
+func (server *Server) serveLocations(w http.ResponseWriter, _ *http.Request) {
+	locations := map[string]any{}
+	err := server.readFixture(fixtureLocations, &locations)
+	if err != nil {
+		server.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, locations)
+}
+
+func (server *Server) serveShifts(w http.ResponseWriter, r *http.Request) {
+	locationID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+		return
+	}
+
+	shifts := map[string][]map[string]any{}
+	err = server.readFixture(fixtureShifts(locationID), &shifts)
+	if err != nil {
+		server.writeError(w, err)
+		return
+	}
+
+	offset, err := server.timeOffset()
+	if err != nil {
+		server.writeError(w, err)
+		return
+	}
+
+	from, _ := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+	until, _ := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+
+	data := make([]map[string]any, 0, len(shifts["data"]))
+	for _, shift := range shifts["data"] {
+		startsAt, endsAt, ok := moveShift(shift, offset)
+		if !ok {
+			// Serve broken shifts as they are, the client has to cope.
+			data = append(data, shift)
+			continue
+		}
+
+		if !from.IsZero() && !endsAt.After(from) {
+			continue
+		}
+		if !until.IsZero() && !startsAt.Before(until) {
+			continue
+		}
+
+		data = append(data, shift)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"data": data})
+}
+
+// timeOffset returns how far shift times have to be moved to appear as if
+// recorded in the current hour.
+func (server *Server) timeOffset() (time.Duration, error) {
+	meta := fixtureMeta{}
+	err := server.readFixture(fixtureMetaFile, &meta)
+	if errors.Is(err, fs.ErrNotExist) || meta.RecordedAt.IsZero() {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return server.config.Clock().Truncate(time.Hour).Sub(meta.RecordedAt.Truncate(time.Hour)), nil
+}
+
+// moveShift moves the shift times by offset.
+func moveShift(shift map[string]any, offset time.Duration) (time.Time, time.Time, bool) {
+	startsAtRaw, _ := shift["starts_at"].(string)
+	endsAtRaw, _ := shift["ends_at"].(string)
+
+	startsAt, err := time.Parse(time.RFC3339Nano, startsAtRaw)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	endsAt, err := time.Parse(time.RFC3339Nano, endsAtRaw)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	startsAt = startsAt.Add(offset)
+	endsAt = endsAt.Add(offset)
+	shift["starts_at"] = startsAt.Format(time.RFC3339)
+	shift["ends_at"] = endsAt.Format(time.RFC3339)
+
+	return startsAt, endsAt, true
+}
+
+func (server *Server) readFixture(name string, v any) error {
+	raw, err := fs.ReadFile(server.config.Fixtures, name)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+func (server *Server) writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+		return
+	}
+
+	slog.Error("failed serving fixture", "error", err.Error())
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "internal server error"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Fixture file layout.
+const (
+	fixtureMetaFile  = "meta.json"
+	fixtureLocations = "locations.json"
+)
+
+func fixtureShifts(locationID int64) string {
+	return path.Join("shifts", strconv.FormatInt(locationID, 10)+".json")
+}
+
+type fixtureMeta struct {
+	RecordedAt time.Time `json:"recorded_at"`
+}
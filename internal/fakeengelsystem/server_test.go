@@ -0,0 +1,122 @@
+package fakeengelsystem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type testShift struct {
+	ID       int64     `json:"id"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+func getShifts(t *testing.T, server *Server, query string) []testShift {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v0-beta/locations/2/shifts"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	shifts := map[string][]testShift{}
+	err := json.Unmarshal(w.Body.Bytes(), &shifts)
+	if err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	return shifts["data"]
+}
+
+func TestServeShiftsClockOffset(t *testing.T) {
+	tests := []struct {
+		name      string
+		clock     time.Time
+		query     string
+		wantStart time.Time
+		wantIDs   []int64
+	}{
+		{
+			name:      "recording time",
+			clock:     time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 5, 31, 17, 0, 0, 0, time.UTC),
+			wantIDs:   []int64{201, 202, 203},
+		},
+		{
+			name:      "moved to the current hour",
+			clock:     time.Date(2024, 12, 27, 9, 59, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 12, 27, 8, 0, 0, 0, time.UTC),
+			wantIDs:   []int64{201, 202, 203},
+		},
+		{
+			name:      "other time zone",
+			clock:     time.Date(2024, 12, 27, 10, 30, 0, 0, time.FixedZone("CET", 3600)),
+			wantStart: time.Date(2024, 12, 27, 8, 0, 0, 0, time.UTC),
+			wantIDs:   []int64{201, 202, 203},
+		},
+		{
+			name:      "filtered by moved times",
+			clock:     time.Date(2024, 12, 27, 9, 0, 0, 0, time.UTC),
+			query:     "?start=2024-12-27T09:00:00Z&end=2024-12-27T10:00:00Z",
+			wantStart: time.Date(2024, 12, 27, 8, 0, 0, 0, time.UTC),
+			wantIDs:   []int64{201},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := New(&Config{Clock: func() time.Time { return tt.clock }})
+
+			shifts := getShifts(t, server, tt.query)
+
+			ids := []int64{}
+			for _, shift := range shifts {
+				ids = append(ids, shift.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Fatalf("shifts %v, want %v", ids, tt.wantIDs)
+			}
+			if !shifts[0].StartsAt.Equal(tt.wantStart) || !shifts[0].EndsAt.Equal(tt.wantStart.Add(time.Hour*3)) {
+				t.Errorf("shift %d from %v to %v, want start %v", shifts[0].ID, shifts[0].StartsAt, shifts[0].EndsAt, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestServeShiftsWithoutRecordingTime(t *testing.T) {
+	server := New(&Config{
+		Fixtures: fstest.MapFS{
+			"shifts/2.json": &fstest.MapFile{Data: []byte(`{"data": [{"id": 201, "starts_at": "2024-05-31T17:00:00Z", "ends_at": "2024-05-31T20:00:00Z"}]}`)},
+		},
+		Clock: func() time.Time { return time.Date(2024, 12, 27, 9, 0, 0, 0, time.UTC) },
+	})
+
+	shifts := getShifts(t, server, "")
+	if len(shifts) != 1 || !shifts[0].StartsAt.Equal(time.Date(2024, 5, 31, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("shifts %v, want shift 201 at the recorded time", shifts)
+	}
+}
+
+func TestServeHTTPRequiresAPIKey(t *testing.T) {
+	server := New(&Config{APIKey: "secret"})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v0-beta/locations", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status without API key = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v0-beta/locations", nil)
+	r.Header.Set("x-api-key", "secret")
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("status with API key = %d, want %d", w.Code, http.StatusOK)
+	}
+}
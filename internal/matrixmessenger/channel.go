@@ -18,11 +18,12 @@ type ChannelResponse struct {
 func (messenger *service) CreateChannel(ctx context.Context, userIdentifier string) (*ChannelResponse, error) {
 	room := mautrix.ReqCreateRoom{
 		Visibility:    "private",
-		RoomAliasName: "RemindMe-" + uniuri.NewLen(5),
-		Name:          "RemindMe",
-		Topic:         "I will be your personal reminder bot",
+		RoomAliasName: "trollinfo-" + uniuri.NewLen(5),
+		Name:          "Trollinfo",
+		Topic:         "I will remind you about your upcoming troll shifts",
 		Invite:        []id.UserID{id.UserID(userIdentifier)},
 		Preset:        "trusted_private_chat",
+		IsDirect:      true,
 	}
 
 	response, err := messenger.client.CreateRoom(ctx, &room)
@@ -0,0 +1,33 @@
+package matrixmessenger
+
+import (
+	"testing"
+
+	"maunium.net/go/mautrix/id"
+)
+
+func TestParseFromEnvironmentKeySharingTrust(t *testing.T) {
+	tests := []struct {
+		value string
+		want  id.TrustState
+	}{
+		{value: "", want: id.TrustStateCrossSignedTOFU},
+		{value: "unverified", want: id.TrustStateUnset},
+		{value: "cross-signed-tofu", want: id.TrustStateCrossSignedTOFU},
+		{value: "verified", want: id.TrustStateVerified},
+		{value: "trust-me", want: id.TrustStateCrossSignedTOFU},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("TROLLINFO_MATRIX_KEY_SHARING_TRUST", tt.value)
+
+			config := Config{}
+			config.ParseFromEnvironment()
+
+			if config.ShareKeysMinTrust != tt.want {
+				t.Errorf("ShareKeysMinTrust = %v, want %v", config.ShareKeysMinTrust, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+//go:build goolm || libolm
+
+package matrixmessenger
+
+import (
+	"context"
+	"errors"
+
+	_ "github.com/mattn/go-sqlite3" // Driver for the crypto store.
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/crypto/cryptohelper"
+)
+
+// setupCrypto enables end-to-end encryption, messages sent to encrypted rooms are encrypted
+// and encrypted events are decrypted before being passed to the handlers.
+// The olm account and megolm sessions are kept in the crypto store so other devices can decrypt
+// messages after a restart.
+func (service *service) setupCrypto(ctx context.Context, client *mautrix.Client) error {
+	if service.config.PickleKey == "" {
+		return errors.New("encryption needs a pickle key")
+	}
+
+	helper, err := cryptohelper.NewCryptoHelper(client, []byte(service.config.PickleKey), service.config.CryptoStorePath)
+	if err != nil {
+		return err
+	}
+
+	err = helper.Init(ctx)
+	if err != nil {
+		return err
+	}
+
+	helper.Machine().SendKeysMinTrust = service.config.ShareKeysMinTrust
+
+	client.Crypto = helper
+	service.closeCrypto = helper.Close
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !goolm && !libolm
+
+package matrixmessenger
+
+import (
+	"context"
+	"errors"
+
+	"maunium.net/go/mautrix"
+)
+
+// setupCrypto fails as the binary was built without olm, build with the goolm or libolm tag.
+func (service *service) setupCrypto(_ context.Context, _ *mautrix.Client) error {
+	return errors.New("built without encryption support, rebuild with -tags goolm")
+}
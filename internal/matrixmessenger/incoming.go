@@ -0,0 +1,129 @@
+package matrixmessenger
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+)
+
+// IncomingMessage holds information about a message received from matrix
+type IncomingMessage struct {
+	EventID                   string // ID of the received message
+	ChannelExternalIdentifier string // Channel ID the message was sent to
+	Sender                    string // Matrix user ID of the sender
+	Body                      string // Plain text body without quoted replies
+	ThreadRootMessage         string // ID of the thread root if the message is part of a thread - optional
+}
+
+// MessageHandler handles a received message
+type MessageHandler func(ctx context.Context, message *IncomingMessage)
+
+// IncomingReaction holds information about a reaction received from matrix
+type IncomingReaction struct {
+	EventID                   string // ID of the received reaction
+	ChannelExternalIdentifier string // Channel ID the reaction was sent to
+	Sender                    string // Matrix user ID of the sender
+	Key                       string // The reaction, usually an emoji
+	ReactedToMessage          string // ID of the message reacted to
+}
+
+// ReactionHandler handles a received reaction
+type ReactionHandler func(ctx context.Context, reaction *IncomingReaction)
+
+// OnMessage registers a handler called for every message received while listening.
+// Messages sent by the bot itself are not passed to handlers.
+func (messenger *service) OnMessage(handler MessageHandler) {
+	messenger.handlersMutex.Lock()
+	defer messenger.handlersMutex.Unlock()
+
+	messenger.handlers = append(messenger.handlers, handler)
+}
+
+// OnReaction registers a handler called for every reaction received while listening.
+// Reactions sent by the bot itself are not passed to handlers.
+func (messenger *service) OnReaction(handler ReactionHandler) {
+	messenger.handlersMutex.Lock()
+	defer messenger.handlersMutex.Unlock()
+
+	messenger.reactionHandlers = append(messenger.reactionHandlers, handler)
+}
+
+// Listen receives events from matrix until the context is canceled.
+func (messenger *service) Listen(ctx context.Context) error {
+	messenger.logger.Infof("Listening for matrix events")
+
+	err := messenger.client.SyncWithContext(ctx)
+	if err != nil && errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	return err
+}
+
+// registerSyncHandlers routes received events to the registered handlers.
+func (messenger *service) registerSyncHandlers(client *mautrix.Client) {
+	syncer, ok := client.Syncer.(*mautrix.DefaultSyncer)
+	if !ok {
+		return
+	}
+
+	// Do not answer messages sent while the bot was offline.
+	syncer.OnSync(client.DontProcessOldEvents)
+	syncer.OnEventType(event.EventMessage, func(ctx context.Context, evt *event.Event) {
+		if evt.Sender == client.UserID {
+			return
+		}
+
+		content := evt.Content.AsMessage()
+		if content == nil || content.MsgType != event.MsgText {
+			return
+		}
+
+		message := &IncomingMessage{
+			EventID:                   evt.ID.String(),
+			ChannelExternalIdentifier: evt.RoomID.String(),
+			Sender:                    evt.Sender.String(),
+			Body:                      strings.TrimSpace(StripReply(content.Body)),
+		}
+		if content.RelatesTo != nil {
+			message.ThreadRootMessage = content.RelatesTo.GetThreadParent().String()
+		}
+
+		messenger.handlersMutex.RLock()
+		handlers := messenger.handlers
+		messenger.handlersMutex.RUnlock()
+
+		for _, handler := range handlers {
+			handler(ctx, message)
+		}
+	})
+	syncer.OnEventType(event.EventReaction, func(ctx context.Context, evt *event.Event) {
+		if evt.Sender == client.UserID {
+			return
+		}
+
+		content := evt.Content.AsReaction()
+		if content == nil || content.RelatesTo.Type != event.RelAnnotation {
+			return
+		}
+
+		reaction := &IncomingReaction{
+			EventID:                   evt.ID.String(),
+			ChannelExternalIdentifier: evt.RoomID.String(),
+			Sender:                    evt.Sender.String(),
+			Key:                       content.RelatesTo.Key,
+			ReactedToMessage:          content.RelatesTo.EventID.String(),
+		}
+
+		messenger.handlersMutex.RLock()
+		handlers := messenger.reactionHandlers
+		messenger.handlersMutex.RUnlock()
+
+		for _, handler := range handlers {
+			handler(ctx, reaction)
+		}
+	})
+}
@@ -18,6 +18,12 @@ type Messenger interface {
 	SendMessageAsync(ctx context.Context, message *Message) error
 	SendMessage(ctx context.Context, message *Message) (*MessageResponse, error)
 	CreateChannel(ctx context.Context, userID string) (*ChannelResponse, error)
+	EditMessage(ctx context.Context, messageID string, message *Message) (*MessageResponse, error)
+	SendReaction(ctx context.Context, reaction string, messageID string, channel string) (*MessageResponse, error)
+	OnMessage(handler MessageHandler)
+	OnReaction(handler ReactionHandler)
+	Listen(ctx context.Context) error
+	Close() error
 	// TODO future improvement: make room member cache flushable throug this interface and flush it on room member updates
 }
 
@@ -32,4 +38,5 @@ type MatrixClient interface {
 	RedactEvent(ctx context.Context, roomID id.RoomID, eventID id.EventID, extra ...mautrix.ReqRedact) (resp *mautrix.RespSendEvent, err error)
 	JoinedMembers(ctx context.Context, roomID id.RoomID) (resp *mautrix.RespJoinedMembers, err error)
 	CreateRoom(ctx context.Context, req *mautrix.ReqCreateRoom) (resp *mautrix.RespCreateRoom, err error)
+	SyncWithContext(ctx context.Context) error
 }
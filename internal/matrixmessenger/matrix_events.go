@@ -21,6 +21,8 @@ var (
 // Relations available
 var (
 	relationAnnotiation = "m.annotation"
+	relationThread      = "m.thread"
+	relationReplace     = "m.replace"
 )
 
 // Mimetypes available for MSC1767 events https://github.com/matrix-org/matrix-spec-proposals/blob/matthew/msc1767/proposals/1767-extensible-events.md
@@ -35,15 +37,20 @@ type messageEvent struct {
 	FormattedBody string `json:"formatted_body,omitempty"`
 	MsgType       string `json:"msgtype,omitempty"`
 	Type          string `json:"type,omitempty"`
-	RelatesTo     struct {
-		EventID   string `json:"event_id,omitempty"`
-		Key       string `json:"key,omitempty"`
-		RelType   string `json:"rel_type,omitempty"`
-		InReplyTo *struct {
-			EventID string `json:"event_id,omitempty"`
-		} `json:"m.in_reply_to,omitempty"`
-	} `json:"m.relates_to,omitempty"`
+	// RelatesTo is nil for events without relation, an empty relation is not allowed.
+	RelatesTo      *relatesTo           `json:"m.relates_to,omitempty"`
 	MSC1767Message []matrixMSC1767Event `json:"org.matrix.msc1767.message,omitempty"`
+	NewContent     *messageEvent        `json:"m.new_content,omitempty"`
+}
+
+type relatesTo struct {
+	EventID       string `json:"event_id,omitempty"`
+	Key           string `json:"key,omitempty"`
+	RelType       string `json:"rel_type,omitempty"`
+	IsFallingBack bool   `json:"is_falling_back,omitempty"`
+	InReplyTo     *struct {
+		EventID string `json:"event_id,omitempty"`
+	} `json:"m.in_reply_to,omitempty"`
 }
 
 func (messageEvent *messageEvent) getEventType() event.Type {
@@ -13,6 +13,7 @@ type Message struct {
 	Body                      string // Plain text message use \n for newlines
 	BodyHTML                  string // HTML formatted message - optional
 	ResponseToMessage         string // ID of a message to respond to - optional
+	ThreadRootMessage         string // ID of the thread root to send the message in - optional
 	ChannelExternalIdentifier string // Channel ID to send the message to
 }
 
@@ -36,12 +37,29 @@ func (message *Message) toEvent() *messageEvent {
 		},
 	}
 
+	if message.ResponseToMessage != "" || message.ThreadRootMessage != "" {
+		messageEvent.RelatesTo = &relatesTo{}
+	}
+
 	if message.ResponseToMessage != "" {
 		messageEvent.RelatesTo.InReplyTo = &struct {
 			EventID string `json:"event_id,omitempty"`
 		}{EventID: message.ResponseToMessage}
 	}
 
+	if message.ThreadRootMessage != "" {
+		messageEvent.RelatesTo.RelType = relationThread
+		messageEvent.RelatesTo.EventID = message.ThreadRootMessage
+
+		// Clients without thread support show the message as reply instead.
+		if messageEvent.RelatesTo.InReplyTo == nil {
+			messageEvent.RelatesTo.IsFallingBack = true
+			messageEvent.RelatesTo.InReplyTo = &struct {
+				EventID string `json:"event_id,omitempty"`
+			}{EventID: message.ThreadRootMessage}
+		}
+	}
+
 	return &messageEvent
 }
 
@@ -84,6 +102,54 @@ func (messenger *service) SendMessage(ctx context.Context, message *Message) (*M
 	return messenger.sendMessage(ctx, message.toEvent(), message.ChannelExternalIdentifier, 3, time.Second*5)
 }
 
+// EditMessage replaces the content of a previously sent message with the given message.
+// Clients not supporting edits show the new content as a separate message.
+func (messenger *service) EditMessage(ctx context.Context, messageID string, message *Message) (*MessageResponse, error) {
+	return messenger.sendMessage(ctx, message.toEditEvent(messageID), message.ChannelExternalIdentifier, 3, time.Second*5)
+}
+
+// toEditEvent converts a Message struct into a matrix event replacing the given message.
+func (message *Message) toEditEvent(messageID string) *messageEvent {
+	// The new content replaces the content only, relations of the original message stay.
+	newContent := message.toEvent()
+	newContent.Type = ""
+	newContent.RelatesTo = nil
+
+	messageEvent := message.toEvent()
+	messageEvent.Body = "* " + message.Body
+	messageEvent.FormattedBody = "* " + message.BodyHTML
+	messageEvent.MSC1767Message = nil
+
+	// Plain text edits must not claim a format.
+	if message.BodyHTML == "" {
+		newContent.Format = ""
+		newContent.FormattedBody = ""
+		messageEvent.Format = ""
+		messageEvent.FormattedBody = ""
+	}
+	messageEvent.RelatesTo = &relatesTo{
+		RelType: relationReplace,
+		EventID: messageID,
+	}
+	messageEvent.NewContent = newContent
+
+	return messageEvent
+}
+
+// SendReaction reacts to the given message via matrix.
+func (messenger *service) SendReaction(ctx context.Context, reaction string, messageID string, channel string) (*MessageResponse, error) {
+	messageEvent := messageEvent{
+		Type: messageTypeReaction,
+	}
+	messageEvent.RelatesTo = &relatesTo{
+		EventID: messageID,
+		Key:     reaction,
+		RelType: relationAnnotiation,
+	}
+
+	return messenger.sendMessage(ctx, &messageEvent, channel, 3, time.Second*5)
+}
+
 // sendMessage will take care of sending the message via matrix
 // The message sending will be tried for retries times and the time between retries is retry * retryTime
 func (messenger *service) sendMessage(ctx context.Context, messageEvent *messageEvent, channel string, retries uint, retryTime time.Duration) (*MessageResponse, error) {
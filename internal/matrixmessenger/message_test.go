@@ -0,0 +1,123 @@
+package matrixmessenger
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMessageEventRelations(t *testing.T) {
+	reply := HTMLMessage("hi", "<b>hi</b>", "!room:example.org")
+	reply.ResponseToMessage = "$original"
+
+	tests := []struct {
+		name  string
+		event *messageEvent
+		want  map[string]any
+	}{
+		{
+			name:  "plain message",
+			event: PlainTextMessage("hi", "!room:example.org").toEvent(),
+			want:  nil,
+		},
+		{
+			name:  "reply",
+			event: reply.toEvent(),
+			want:  map[string]any{"m.in_reply_to": map[string]any{"event_id": "$original"}},
+		},
+		{
+			name:  "edit",
+			event: reply.toEditEvent("$sent"),
+			want:  map[string]any{"rel_type": "m.replace", "event_id": "$sent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := marshalToMap(t, tt.event)
+
+			relation, ok := content["m.relates_to"]
+			if tt.want == nil && ok {
+				t.Errorf("m.relates_to = %v, want none", relation)
+			}
+			if tt.want != nil && !reflect.DeepEqual(relation, tt.want) {
+				t.Errorf("m.relates_to = %v, want %v", relation, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditEventNewContent(t *testing.T) {
+	message := HTMLMessage("updated", "<b>updated</b>", "!room:example.org")
+	message.ResponseToMessage = "$original"
+
+	content := marshalToMap(t, message.toEditEvent("$sent"))
+
+	if content["body"] != "* updated" {
+		t.Errorf("body = %v, want fallback \"* updated\"", content["body"])
+	}
+
+	newContent, ok := content["m.new_content"].(map[string]any)
+	if !ok {
+		t.Fatalf("m.new_content missing in %v", content)
+	}
+	if newContent["body"] != "updated" || newContent["formatted_body"] != "<b>updated</b>" {
+		t.Errorf("m.new_content = %v, want the updated body", newContent)
+	}
+	if relation, ok := newContent["m.relates_to"]; ok {
+		t.Errorf("m.new_content contains m.relates_to %v", relation)
+	}
+}
+
+func TestEditEventFormat(t *testing.T) {
+	tests := []struct {
+		name              string
+		message           *Message
+		wantFormat        any
+		wantFormattedBody any
+		wantNewFormatted  any
+	}{
+		{
+			name:              "html",
+			message:           HTMLMessage("updated", "<b>updated</b>", "!room:example.org"),
+			wantFormat:        formatCustomHTML,
+			wantFormattedBody: "* <b>updated</b>",
+			wantNewFormatted:  "<b>updated</b>",
+		},
+		{
+			name:    "without html",
+			message: &Message{Body: "updated", ChannelExternalIdentifier: "!room:example.org"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := marshalToMap(t, tt.message.toEditEvent("$sent"))
+			newContent, _ := content["m.new_content"].(map[string]any)
+
+			if content["format"] != tt.wantFormat || content["formatted_body"] != tt.wantFormattedBody {
+				t.Errorf("format = %v, formatted_body = %v, want %v, %v", content["format"], content["formatted_body"], tt.wantFormat, tt.wantFormattedBody)
+			}
+			if newContent["format"] != tt.wantFormat || newContent["formatted_body"] != tt.wantNewFormatted {
+				t.Errorf("m.new_content format = %v, formatted_body = %v, want %v, %v", newContent["format"], newContent["formatted_body"], tt.wantFormat, tt.wantNewFormatted)
+			}
+		})
+	}
+}
+
+func marshalToMap(t *testing.T, event *messageEvent) map[string]any {
+	t.Helper()
+
+	raw, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	content := map[string]any{}
+	err = json.Unmarshal(raw, &content)
+	if err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	return content
+}
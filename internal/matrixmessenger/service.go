@@ -2,6 +2,7 @@ package matrixmessenger
 
 import (
 	"context"
+	"log/slog"
 	"os"
 	"sync"
 	"time"
@@ -18,6 +19,12 @@ type service struct {
 	client        MatrixClient
 	logger        gologger.Logger
 	state         *state
+
+	handlers         []MessageHandler
+	reactionHandlers []ReactionHandler
+	handlersMutex    sync.RWMutex
+
+	closeCrypto func() error
 }
 
 type Config struct {
@@ -25,6 +32,22 @@ type Config struct {
 	Password   string
 	Homeserver string
 	DeviceID   string
+
+	// AccessToken is used instead of the password if set and accepted.
+	AccessToken string
+	// SessionPath is the file the session is stored in to reuse it after a restart.
+	SessionPath  string
+	LoginTimeout time.Duration
+
+	// Encryption enables end-to-end encryption, the olm state is kept in CryptoStorePath
+	// and encrypted with PickleKey.
+	Encryption      bool
+	CryptoStorePath string
+	PickleKey       string
+	// ShareKeysMinTrust is the trust a device needs to receive room keys, by default only
+	// cross-signed devices receive them. Sharing with unverified devices has to be chosen
+	// explicitly.
+	ShareKeysMinTrust id.TrustState
 }
 
 // ParseFromEnvironment parses the config from the environment.
@@ -33,6 +56,35 @@ func (c *Config) ParseFromEnvironment() {
 	c.Password = os.Getenv("TROLLINFO_MATRIX_PASSWORD")
 	c.Homeserver = os.Getenv("TROLLINFO_MATRIX_HOMESERVER")
 	c.DeviceID = os.Getenv("TROLLINFO_MATRIX_DEVICE_ID")
+	c.AccessToken = os.Getenv("TROLLINFO_MATRIX_ACCESS_TOKEN")
+	c.SessionPath = os.Getenv("TROLLINFO_MATRIX_SESSION_PATH")
+	if c.SessionPath == "" {
+		c.SessionPath = "trollinfo-session.json"
+	}
+	c.LoginTimeout = time.Second * 30
+	if loginTimeout := os.Getenv("TROLLINFO_MATRIX_LOGIN_TIMEOUT"); loginTimeout != "" {
+		parsed, err := time.ParseDuration(loginTimeout)
+		if err != nil || parsed <= 0 {
+			slog.Warn("invalid matrix login timeout, using default", "value", loginTimeout)
+		} else {
+			c.LoginTimeout = parsed
+		}
+	}
+	c.Encryption = os.Getenv("TROLLINFO_MATRIX_ENCRYPTION") == "true"
+	c.CryptoStorePath = os.Getenv("TROLLINFO_MATRIX_CRYPTO_STORE_PATH")
+	if c.CryptoStorePath == "" {
+		c.CryptoStorePath = "trollinfo-crypto.db"
+	}
+	c.PickleKey = os.Getenv("TROLLINFO_MATRIX_PICKLE_KEY")
+	c.ShareKeysMinTrust = id.TrustStateCrossSignedTOFU
+	if minTrust := os.Getenv("TROLLINFO_MATRIX_KEY_SHARING_TRUST"); minTrust != "" {
+		parsed := id.ParseTrustState(minTrust)
+		if parsed == id.TrustStateInvalid {
+			slog.Warn("invalid matrix key sharing trust, using default", "value", minTrust)
+		} else {
+			c.ShareKeysMinTrust = parsed
+		}
+	}
 }
 
 type state struct {
@@ -58,7 +110,7 @@ func NewMessenger(config *Config, logger gologger.Logger) (Messenger, error) {
 func (service *service) setupMautrixClient() error {
 	service.logger.Debugf("setting up mautrix client ...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	ctx, cancel := context.WithTimeout(context.Background(), service.config.LoginTimeout)
 	defer cancel()
 
 	client, err := mautrix.NewClient(service.config.Homeserver, "", "")
@@ -67,17 +119,35 @@ func (service *service) setupMautrixClient() error {
 	}
 
 	service.client = client
+	service.registerSyncHandlers(client)
 
-	_, err = client.Login(ctx, &mautrix.ReqLogin{
-		Type:             "m.login.password",
-		Identifier:       mautrix.UserIdentifier{Type: mautrix.IdentifierTypeUser, User: service.config.Username},
-		Password:         service.config.Password,
-		DeviceID:         id.DeviceID(service.config.DeviceID),
-		StoreCredentials: true,
-	})
+	err = service.login(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if service.config.Encryption {
+		cryptoCtx, cancel := context.WithTimeout(context.Background(), service.config.LoginTimeout)
+		defer cancel()
+
+		err = service.setupCrypto(cryptoCtx, client)
+		if err != nil {
+			return err
+		}
+		service.logger.Debugf("end-to-end encryption enabled")
+	}
 
 	service.logger.Debugf("matrix client setup finished")
-	return err
+	return nil
+}
+
+// Close releases the crypto store.
+func (service *service) Close() error {
+	if service.closeCrypto == nil {
+		return nil
+	}
+
+	return service.closeCrypto()
 }
 
 // sendMessageEvent sends a message event to matrix, will take care of encryption if available
@@ -86,24 +156,6 @@ func (messenger *service) sendMessageEvent(ctx context.Context, messageEvent *me
 	return messenger.client.SendMessageEvent(ctx, id.RoomID(roomID), eventType, &messageEvent)
 }
 
-// enrichCleartext adds parts of the encrypted event back into the cleartext event as specified by the matrix spec
-func enrichCleartext(encryptedEvent *event.EncryptedEventContent, evt *messageEvent) {
-	if evt.RelatesTo.EventID == "" && evt.RelatesTo.InReplyTo == nil {
-		return
-	}
-
-	encryptedEvent.RelatesTo = &event.RelatesTo{}
-	encryptedEvent.RelatesTo.EventID = id.EventID(evt.RelatesTo.EventID)
-	encryptedEvent.RelatesTo.Key = evt.RelatesTo.Key
-	encryptedEvent.RelatesTo.Type = event.RelationType(evt.RelatesTo.RelType)
-
-	if evt.RelatesTo.InReplyTo != nil {
-		encryptedEvent.RelatesTo.InReplyTo = &event.InReplyTo{
-			EventID: id.EventID(evt.RelatesTo.InReplyTo.EventID),
-		}
-	}
-}
-
 func (messenger *service) getUserIDsInRoom(ctx context.Context, roomID id.RoomID) []id.UserID {
 	// Check cache first
 	if users := messenger.roomUserCache.GetUsers(roomID); users != nil {
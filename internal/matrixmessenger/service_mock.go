@@ -34,6 +34,20 @@ func (m *MockMessenger) EXPECT() *MockMessengerMockRecorder {
 	return m.recorder
 }
 
+// Close mocks base method.
+func (m *MockMessenger) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockMessengerMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMessenger)(nil).Close))
+}
+
 // CreateChannel mocks base method.
 func (m *MockMessenger) CreateChannel(arg0 context.Context, arg1 string) (*ChannelResponse, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +63,59 @@ func (mr *MockMessengerMockRecorder) CreateChannel(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateChannel", reflect.TypeOf((*MockMessenger)(nil).CreateChannel), arg0, arg1)
 }
 
+// EditMessage mocks base method.
+func (m *MockMessenger) EditMessage(arg0 context.Context, arg1 string, arg2 *Message) (*MessageResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditMessage", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*MessageResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EditMessage indicates an expected call of EditMessage.
+func (mr *MockMessengerMockRecorder) EditMessage(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditMessage", reflect.TypeOf((*MockMessenger)(nil).EditMessage), arg0, arg1, arg2)
+}
+
+// Listen mocks base method.
+func (m *MockMessenger) Listen(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Listen", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Listen indicates an expected call of Listen.
+func (mr *MockMessengerMockRecorder) Listen(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Listen", reflect.TypeOf((*MockMessenger)(nil).Listen), arg0)
+}
+
+// OnMessage mocks base method.
+func (m *MockMessenger) OnMessage(arg0 MessageHandler) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnMessage", arg0)
+}
+
+// OnMessage indicates an expected call of OnMessage.
+func (mr *MockMessengerMockRecorder) OnMessage(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnMessage", reflect.TypeOf((*MockMessenger)(nil).OnMessage), arg0)
+}
+
+// OnReaction mocks base method.
+func (m *MockMessenger) OnReaction(arg0 ReactionHandler) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnReaction", arg0)
+}
+
+// OnReaction indicates an expected call of OnReaction.
+func (mr *MockMessengerMockRecorder) OnReaction(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnReaction", reflect.TypeOf((*MockMessenger)(nil).OnReaction), arg0)
+}
+
 // SendMessage mocks base method.
 func (m *MockMessenger) SendMessage(arg0 context.Context, arg1 *Message) (*MessageResponse, error) {
 	m.ctrl.T.Helper()
@@ -77,3 +144,18 @@ func (mr *MockMessengerMockRecorder) SendMessageAsync(arg0, arg1 interface{}) *g
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessageAsync", reflect.TypeOf((*MockMessenger)(nil).SendMessageAsync), arg0, arg1)
 }
+
+// SendReaction mocks base method.
+func (m *MockMessenger) SendReaction(arg0 context.Context, arg1, arg2, arg3 string) (*MessageResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendReaction", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*MessageResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendReaction indicates an expected call of SendReaction.
+func (mr *MockMessengerMockRecorder) SendReaction(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendReaction", reflect.TypeOf((*MockMessenger)(nil).SendReaction), arg0, arg1, arg2, arg3)
+}
@@ -0,0 +1,137 @@
+package matrixmessenger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+)
+
+// session holds the credentials obtained on login
+type session struct {
+	Homeserver  string      `json:"homeserver"`
+	UserID      id.UserID   `json:"user_id"`
+	DeviceID    id.DeviceID `json:"device_id"`
+	AccessToken string      `json:"access_token"`
+}
+
+// login authenticates the client, reusing the configured access token or the stored session.
+// If both are rejected the client logs in with the password and stores the new session. The
+// stored session is also tried after a rejected configured token, otherwise every restart
+// would log in again.
+func (service *service) login(ctx context.Context, client *mautrix.Client) error {
+	if service.config.AccessToken != "" {
+		ok, err := service.useAccessToken(ctx, client, service.config.AccessToken)
+		if err != nil || ok {
+			return err
+		}
+		service.logger.Infof("configured matrix access token rejected")
+	}
+
+	stored, err := service.loadSession()
+	switch {
+	case err != nil:
+		service.logger.Infof("no stored matrix session available: %s", err.Error())
+	case stored.Homeserver != service.config.Homeserver || !service.isConfiguredUser(stored.UserID):
+		service.logger.Infof("stored matrix session of %s does not match the config, ignoring it", stored.UserID)
+	case stored.AccessToken != service.config.AccessToken:
+		ok, err := service.useAccessToken(ctx, client, stored.AccessToken)
+		if err != nil || ok {
+			return err
+		}
+		service.logger.Infof("stored matrix access token rejected")
+	}
+
+	service.logger.Infof("logging in to matrix with password")
+	resp, err := client.Login(ctx, &mautrix.ReqLogin{
+		Type:             "m.login.password",
+		Identifier:       mautrix.UserIdentifier{Type: mautrix.IdentifierTypeUser, User: service.config.Username},
+		Password:         service.config.Password,
+		DeviceID:         id.DeviceID(service.config.DeviceID),
+		StoreCredentials: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = service.saveSession(&session{
+		Homeserver:  service.config.Homeserver,
+		UserID:      resp.UserID,
+		DeviceID:    resp.DeviceID,
+		AccessToken: resp.AccessToken,
+	})
+	if err != nil {
+		// The session still works, it is just not reused after a restart.
+		service.logger.Infof("failed to store matrix session: %s", err.Error())
+	}
+
+	return nil
+}
+
+// useAccessToken sets the access token if the homeserver accepts it for the
+// configured user.
+func (service *service) useAccessToken(ctx context.Context, client *mautrix.Client, accessToken string) (bool, error) {
+	client.AccessToken = accessToken
+	whoami, err := client.Whoami(ctx)
+	if err != nil {
+		client.AccessToken = ""
+		if errors.Is(err, mautrix.MUnknownToken) || errors.Is(err, mautrix.MMissingToken) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !service.isConfiguredUser(whoami.UserID) {
+		client.AccessToken = ""
+		service.logger.Infof("matrix access token belongs to %s, not the configured user", whoami.UserID)
+		return false, nil
+	}
+
+	client.UserID = whoami.UserID
+	client.DeviceID = whoami.DeviceID
+	service.logger.Debugf("reusing matrix session of device %s", whoami.DeviceID)
+	return true, nil
+}
+
+// isConfiguredUser compares the user ID with the configured username, which
+// is either a full user ID or the localpart. Any user matches if no username
+// is configured.
+func (service *service) isConfiguredUser(userID id.UserID) bool {
+	username := service.config.Username
+	if username == "" {
+		return true
+	}
+	if strings.HasPrefix(username, "@") {
+		return userID == id.UserID(username)
+	}
+
+	return userID.Localpart() == username
+}
+
+func (service *service) loadSession() (*session, error) {
+	data, err := os.ReadFile(service.config.SessionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &session{}
+	err = json.Unmarshal(data, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+func (service *service) saveSession(stored *session) error {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	// The file contains the access token, keep it private.
+	return os.WriteFile(service.config.SessionPath, data, 0o600)
+}
@@ -0,0 +1,149 @@
+package matrixmessenger
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CubicrootXYZ/gologger"
+	"maunium.net/go/mautrix"
+)
+
+// fakeHomeserver accepts the known access tokens and hands out new ones on
+// password login.
+type fakeHomeserver struct {
+	mutex  sync.Mutex
+	tokens map[string]string
+	logins int
+}
+
+func (homeserver *fakeHomeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	homeserver.mutex.Lock()
+	defer homeserver.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/_matrix/client/v3/account/whoami":
+		userID, ok := homeserver.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"unknown token"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"user_id": userID, "device_id": "DEVICE"})
+	case "/_matrix/client/v3/login":
+		homeserver.logins++
+		token := "login-" + strconv.Itoa(homeserver.logins)
+		homeserver.tokens[token] = "@trollbot:example.org"
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"user_id":      "@trollbot:example.org",
+			"device_id":    "DEVICE",
+			"access_token": token,
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name        string
+		accessToken string
+		stored      *session
+		wantToken   string
+		wantLogins  int
+	}{
+		{
+			name:        "configured token",
+			accessToken: "valid",
+			wantToken:   "valid",
+		},
+		{
+			name:      "stored session",
+			stored:    &session{UserID: "@trollbot:example.org", AccessToken: "stored"},
+			wantToken: "stored",
+		},
+		{
+			name:        "stored session after rejected token",
+			accessToken: "revoked",
+			stored:      &session{UserID: "@trollbot:example.org", AccessToken: "stored"},
+			wantToken:   "stored",
+		},
+		{
+			name:        "password after rejected tokens",
+			accessToken: "revoked",
+			stored:      &session{UserID: "@trollbot:example.org", AccessToken: "revoked-too"},
+			wantToken:   "login-1",
+			wantLogins:  1,
+		},
+		{
+			name:       "stored session of other user",
+			stored:     &session{UserID: "@other:example.org", AccessToken: "other"},
+			wantToken:  "login-1",
+			wantLogins: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			homeserver := &fakeHomeserver{tokens: map[string]string{
+				"valid":  "@trollbot:example.org",
+				"stored": "@trollbot:example.org",
+				"other":  "@other:example.org",
+			}}
+			server := httptest.NewServer(homeserver)
+			t.Cleanup(server.Close)
+
+			service := &service{
+				config: &Config{
+					Username:    "trollbot",
+					Password:    "secret",
+					Homeserver:  server.URL,
+					AccessToken: tt.accessToken,
+					SessionPath: filepath.Join(t.TempDir(), "session.json"),
+				},
+				logger: gologger.New(gologger.LogLevelDebug, 0),
+			}
+			if tt.stored != nil {
+				tt.stored.Homeserver = server.URL
+				err := service.saveSession(tt.stored)
+				if err != nil {
+					t.Fatalf("saveSession() error = %v", err)
+				}
+			}
+
+			client, err := mautrix.NewClient(server.URL, "", "")
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			err = service.login(context.Background(), client)
+			if err != nil {
+				t.Fatalf("login() error = %v", err)
+			}
+			if client.AccessToken != tt.wantToken {
+				t.Errorf("access token = %q, want %q", client.AccessToken, tt.wantToken)
+			}
+			if homeserver.logins != tt.wantLogins {
+				t.Errorf("password logins = %d, want %d", homeserver.logins, tt.wantLogins)
+			}
+			if client.UserID != "@trollbot:example.org" {
+				t.Errorf("user ID = %q, want @trollbot:example.org", client.UserID)
+			}
+
+			// The next start reuses the session of the password login.
+			if tt.wantLogins > 0 {
+				stored, err := service.loadSession()
+				if err != nil || stored.AccessToken != tt.wantToken {
+					t.Errorf("stored session = %+v (%v), want access token %q", stored, err, tt.wantToken)
+				}
+			}
+		})
+	}
+}
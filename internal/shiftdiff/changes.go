@@ -0,0 +1,178 @@
+package shiftdiff
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+)
+
+// Change describes a single difference between two states of a shift.
+type Change struct {
+	ShiftID       int64
+	ShiftName     string
+	ShiftStartsAt time.Time
+	AngelType     string
+
+	// User is set if a user joined or left the shift.
+	User   *User
+	Joined bool
+
+	// NeedsBefore and NeedsAfter are set if the needed amount of users
+	// changed.
+	NeedsBefore int64
+	NeedsAfter  int64
+}
+
+// Key identifies what changed, e.g. a user in a shift. Changes reverting each
+// other share the same key.
+func (change *Change) Key() string {
+	key := strconv.FormatInt(change.ShiftID, 10) + "/" + change.AngelType
+	if change.User != nil {
+		key += "/" + strconv.FormatInt(change.User.ID, 10)
+	}
+
+	return key
+}
+
+// IsNeedsChange returns true if the change is about the needed amount of
+// users.
+func (change *Change) IsNeedsChange() bool {
+	return change.User == nil
+}
+
+// CompareShifts returns the changes from previous to current. Only shifts
+// contained in both are compared, as they might be fetched for different
+// time windows.
+func CompareShifts(previous, current []angelapi.Shift) []Change {
+	changes := []Change{}
+
+	for _, currentShift := range current {
+		i := slices.IndexFunc(previous, func(shift angelapi.Shift) bool {
+			return shift.ID == currentShift.ID
+		})
+		if i < 0 {
+			continue
+		}
+		previousShift := previous[i]
+
+		for _, angelType := range angelTypes(previousShift, currentShift) {
+			previousEntry := findEntry(previousShift, angelType)
+			currentEntry := findEntry(currentShift, angelType)
+
+			if previousEntry.Needs != currentEntry.Needs {
+				changes = append(changes, newChange(currentShift, angelType, func(change *Change) {
+					change.NeedsBefore = previousEntry.Needs
+					change.NeedsAfter = currentEntry.Needs
+				}))
+			}
+
+			for _, user := range currentEntry.Users {
+				if !hasUser(previousEntry, user.ID) {
+					changes = append(changes, newUserChange(currentShift, currentEntry, user, true))
+				}
+			}
+			for _, user := range previousEntry.Users {
+				if !hasUser(currentEntry, user.ID) {
+					changes = append(changes, newUserChange(currentShift, previousEntry, user, false))
+				}
+			}
+		}
+	}
+
+	return changes
+}
+
+// Apply applies the change to the matching shift in shifts.
+func Apply(shifts []angelapi.Shift, change *Change) {
+	i := slices.IndexFunc(shifts, func(shift angelapi.Shift) bool {
+		return shift.ID == change.ShiftID
+	})
+	if i < 0 {
+		return
+	}
+
+	// Copy entries to not modify snapshots sharing them.
+	entries := slices.Clone(shifts[i].Entries)
+	j := slices.IndexFunc(entries, func(entry angelapi.ShiftEntry) bool {
+		return entry.Type.Name == change.AngelType
+	})
+	if j < 0 {
+		entries = append(entries, angelapi.ShiftEntry{Type: angelapi.ShiftType{Name: change.AngelType}})
+		j = len(entries) - 1
+	}
+
+	entry := &entries[j]
+	switch {
+	case change.IsNeedsChange():
+		entry.Needs = change.NeedsAfter
+	case change.Joined:
+		entry.Users = append(slices.Clone(entry.Users), angelapi.User{ID: change.User.ID, NickName: change.User.Nickname})
+	default:
+		entry.Users = slices.DeleteFunc(slices.Clone(entry.Users), func(user angelapi.User) bool {
+			return user.ID == change.User.ID
+		})
+	}
+
+	shifts[i].Entries = entries
+}
+
+func newChange(shift angelapi.Shift, angelType string, modify func(*Change)) Change {
+	change := Change{
+		ShiftID:       shift.ID,
+		ShiftName:     shift.Title,
+		ShiftStartsAt: shift.StartsAt,
+		AngelType:     angelType,
+	}
+	modify(&change)
+
+	return change
+}
+
+func newUserChange(shift angelapi.Shift, entry angelapi.ShiftEntry, user angelapi.User, joined bool) Change {
+	return newChange(shift, entry.Type.Name, func(change *Change) {
+		change.Joined = joined
+		change.User = &User{
+			ID:        user.ID,
+			Nickname:  user.NickName,
+			AngelType: entry.Type.Name,
+			ShiftName: shift.Title,
+		}
+	})
+}
+
+// angelTypes returns the angel type names used in any of the shifts.
+func angelTypes(shifts ...angelapi.Shift) []string {
+	names := []string{}
+	for _, shift := range shifts {
+		for _, entry := range shift.Entries {
+			if !slices.Contains(names, entry.Type.Name) {
+				names = append(names, entry.Type.Name)
+			}
+		}
+	}
+
+	return names
+}
+
+// findEntry returns the entry for the angel type or an empty one.
+func findEntry(shift angelapi.Shift, angelType string) angelapi.ShiftEntry {
+	for _, entry := range shift.Entries {
+		if entry.Type.Name == angelType {
+			return entry
+		}
+	}
+
+	return angelapi.ShiftEntry{}
+}
+
+func hasUser(entry angelapi.ShiftEntry, userID int64) bool {
+	for _, user := range entry.Users {
+		if user.ID == userID {
+			return true
+		}
+	}
+
+	return false
+}
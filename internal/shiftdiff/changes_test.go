@@ -0,0 +1,108 @@
+package shiftdiff
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+)
+
+func TestCompareShifts(t *testing.T) {
+	end := testShiftStart.Add(time.Hour * 2)
+	userChange := func(userID int64, angelType string, joined bool) Change {
+		user := testUser(userID, angelType, "Late")
+		return Change{ShiftID: 1, ShiftName: "Late", ShiftStartsAt: testShiftStart, AngelType: angelType, User: &user, Joined: joined}
+	}
+
+	tests := []struct {
+		name     string
+		previous []angelapi.Shift
+		current  []angelapi.Shift
+		want     []Change
+	}{
+		{
+			name:     "unchanged",
+			previous: []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2, 1, 2))},
+			current:  []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2, 2, 1))},
+			want:     []Change{},
+		},
+		{
+			name:     "users joining and leaving",
+			previous: []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2, 1, 2))},
+			current:  []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2, 2, 3))},
+			want: []Change{
+				userChange(3, "Bar", true),
+				userChange(1, "Bar", false),
+			},
+		},
+		{
+			name:     "needs changed and new entry",
+			previous: []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2))},
+			current:  []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 3), testEntry("Cashier", 1, 4))},
+			want: []Change{
+				{ShiftID: 1, ShiftName: "Late", ShiftStartsAt: testShiftStart, AngelType: "Bar", NeedsBefore: 2, NeedsAfter: 3},
+				{ShiftID: 1, ShiftName: "Late", ShiftStartsAt: testShiftStart, AngelType: "Cashier", NeedsBefore: 0, NeedsAfter: 1},
+				userChange(4, "Cashier", true),
+			},
+		},
+		{
+			name:     "shifts only in one list are ignored",
+			previous: []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2, 1))},
+			current:  []angelapi.Shift{testShift(2, "Night", end, end.Add(time.Hour), testEntry("Bar", 2, 2))},
+			want:     []Change{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareShifts(tt.previous, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareShifts() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	end := testShiftStart.Add(time.Hour * 2)
+
+	tests := []struct {
+		name     string
+		previous func() []angelapi.Shift
+		current  []angelapi.Shift
+	}{
+		{
+			name: "users joining and leaving",
+			previous: func() []angelapi.Shift {
+				return []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2, 1, 2))}
+			},
+			current: []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2, 2, 3))},
+		},
+		{
+			name: "needs changed and new entry",
+			previous: func() []angelapi.Shift {
+				return []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 2))}
+			},
+			current: []angelapi.Shift{testShift(1, "Late", testShiftStart, end, testEntry("Bar", 3), testEntry("Cashier", 1, 4))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := tt.previous()
+			shifts := append([]angelapi.Shift{}, previous...)
+
+			for _, change := range CompareShifts(previous, tt.current) {
+				Apply(shifts, &change)
+			}
+
+			if remaining := CompareShifts(shifts, tt.current); len(remaining) > 0 {
+				t.Errorf("changes left after applying all changes: %+v", remaining)
+			}
+			if !reflect.DeepEqual(previous, tt.previous()) {
+				t.Errorf("Apply() modified the previous snapshot: %+v", previous)
+			}
+		})
+	}
+}
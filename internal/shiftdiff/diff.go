@@ -0,0 +1,153 @@
+package shiftdiff
+
+import (
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+)
+
+// leavingGracePeriod extends the window for leaving users, shifts ending
+// shortly after the next shift starts are still handed over.
+const leavingGracePeriod = time.Minute
+
+// User represents a user in a diff.
+type User struct {
+	ID        int64
+	Nickname  string
+	AngelType string
+	ShiftName string
+	// CheckedIn is set once an arriving user acknowledged the shift.
+	CheckedIn bool
+}
+
+// Diff holds the trolls changing in a location around a reference time.
+type Diff struct {
+	UsersLeaving  []User
+	UsersWorking  []User
+	UsersArriving []User
+	// ExpectedUsers is the amount of trolls needed in arriving shifts.
+	ExpectedUsers int64
+	// OpenUsers holds the amount of unfilled positions in arriving shifts
+	// per angel type.
+	OpenUsers map[string]int64
+}
+
+// Compute classifies the users of the given shifts relative to refTime.
+//
+// Users of shifts starting within window are arriving, users of running
+// shifts ending within window are leaving and users of other running shifts
+// are working. Users that are arriving while already being in the location
+// are working as well. Users are matched by ID.
+func Compute(shifts []angelapi.Shift, refTime time.Time, window time.Duration) Diff {
+	diff := Diff{
+		UsersLeaving:  []User{},
+		UsersWorking:  []User{},
+		UsersArriving: []User{},
+		OpenUsers:     map[string]int64{},
+	}
+
+	arriving := []User{}
+	working := []User{}
+	leaving := []User{}
+
+	for _, shift := range shifts {
+		timeUntilShiftStart := shift.StartsAt.Sub(refTime)
+		timeUntilShiftEnd := shift.EndsAt.Sub(refTime)
+
+		switch {
+		case timeUntilShiftStart > 0 && timeUntilShiftStart <= window:
+			// Next shift, users should arrive.
+			for _, entry := range shift.Entries {
+				diff.ExpectedUsers += entry.Needs
+				arriving = append(arriving, toUsers(shift, entry)...)
+			}
+			for angelType, open := range OpenPositions(shift) {
+				diff.OpenUsers[angelType] += open
+			}
+
+		case timeUntilShiftStart <= 0 && timeUntilShiftEnd > 0:
+			// Running shift, users should leave or stay.
+			for _, entry := range shift.Entries {
+				if timeUntilShiftEnd <= window+leavingGracePeriod {
+					leaving = append(leaving, toUsers(shift, entry)...)
+				} else {
+					working = append(working, toUsers(shift, entry)...)
+				}
+			}
+		}
+	}
+
+	present := append(append([]User{}, working...), leaving...)
+
+	arrivingIDs := map[int64]bool{}
+	for _, user := range arriving {
+		if arrivingIDs[user.ID] {
+			continue
+		}
+		arrivingIDs[user.ID] = true
+
+		if containsUser(present, user.ID) {
+			diff.UsersWorking = append(diff.UsersWorking, user)
+			continue
+		}
+
+		diff.UsersArriving = append(diff.UsersArriving, user)
+	}
+
+	// Working users come first, so users with a running shift next to the
+	// one ending are not listed as leaving.
+	seenIDs := map[int64]bool{}
+	for i, user := range present {
+		// Users with a follow-up shift are listed with that one.
+		if arrivingIDs[user.ID] || seenIDs[user.ID] {
+			continue
+		}
+		seenIDs[user.ID] = true
+
+		if i >= len(working) {
+			diff.UsersLeaving = append(diff.UsersLeaving, user)
+			continue
+		}
+
+		diff.UsersWorking = append(diff.UsersWorking, user)
+	}
+
+	return diff
+}
+
+// OpenPositions returns the amount of unfilled positions in the shift per
+// angel type. Angel types without open positions are omitted.
+func OpenPositions(shift angelapi.Shift) map[string]int64 {
+	open := map[string]int64{}
+	for _, entry := range shift.Entries {
+		if missing := entry.Needs - int64(len(entry.Users)); missing > 0 {
+			open[entry.Type.Name] += missing
+		}
+	}
+
+	return open
+}
+
+func toUsers(shift angelapi.Shift, entry angelapi.ShiftEntry) []User {
+	users := make([]User, 0, len(entry.Users))
+	for _, user := range entry.Users {
+		users = append(users, User{
+			ID:        user.ID,
+			Nickname:  user.NickName,
+			AngelType: entry.Type.Name,
+			ShiftName: shift.Title,
+		})
+	}
+
+	return users
+}
+
+func containsUser(users []User, userID int64) bool {
+	for _, user := range users {
+		if user.ID == userID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,200 @@
+package shiftdiff
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+)
+
+var testShiftStart = time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC)
+
+func testShift(id int64, title string, startsAt, endsAt time.Time, entries ...angelapi.ShiftEntry) angelapi.Shift {
+	return angelapi.Shift{
+		ID:       id,
+		Title:    title,
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+		Entries:  entries,
+	}
+}
+
+func testEntry(angelType string, needs int64, userIDs ...int64) angelapi.ShiftEntry {
+	entry := angelapi.ShiftEntry{
+		Type:  angelapi.ShiftType{Name: angelType},
+		Needs: needs,
+		Users: []angelapi.User{},
+	}
+	for _, userID := range userIDs {
+		entry.Users = append(entry.Users, angelapi.User{ID: userID, NickName: "Troll " + strconv.FormatInt(userID, 10)})
+	}
+
+	return entry
+}
+
+func testUser(id int64, angelType, shiftName string) User {
+	return User{
+		ID:        id,
+		Nickname:  "Troll " + strconv.FormatInt(id, 10),
+		AngelType: angelType,
+		ShiftName: shiftName,
+	}
+}
+
+func TestCompute(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 31, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		shifts []angelapi.Shift
+		want   Diff
+	}{
+		{
+			name: "back-to-back shifts",
+			shifts: []angelapi.Shift{
+				testShift(1, "Early", at(16, 0), at(18, 0), testEntry("Bar", 2, 1, 2)),
+				testShift(2, "Late", at(18, 0), at(20, 0), testEntry("Bar", 2, 2, 3)),
+			},
+			want: Diff{
+				UsersLeaving:  []User{testUser(1, "Bar", "Early")},
+				UsersWorking:  []User{testUser(2, "Bar", "Late")},
+				UsersArriving: []User{testUser(3, "Bar", "Late")},
+				ExpectedUsers: 2,
+				OpenUsers:     map[string]int64{},
+			},
+		},
+		{
+			name: "shift ending within the grace period",
+			shifts: []angelapi.Shift{
+				testShift(1, "Early", at(16, 0), at(18, 1), testEntry("Bar", 1, 1)),
+			},
+			want: Diff{
+				UsersLeaving:  []User{testUser(1, "Bar", "Early")},
+				UsersWorking:  []User{},
+				UsersArriving: []User{},
+				OpenUsers:     map[string]int64{},
+			},
+		},
+		{
+			name: "overlapping shifts",
+			shifts: []angelapi.Shift{
+				testShift(1, "Long", at(16, 0), at(20, 0), testEntry("Bar", 2, 1, 4)),
+				testShift(2, "Short", at(17, 0), at(18, 0), testEntry("Bar", 2, 2, 4)),
+				testShift(3, "Late", at(18, 0), at(22, 0), testEntry("Bar", 1, 3)),
+			},
+			want: Diff{
+				UsersLeaving: []User{testUser(2, "Bar", "Short")},
+				UsersWorking: []User{
+					testUser(1, "Bar", "Long"),
+					testUser(4, "Bar", "Long"),
+				},
+				UsersArriving: []User{testUser(3, "Bar", "Late")},
+				ExpectedUsers: 1,
+				OpenUsers:     map[string]int64{},
+			},
+		},
+		{
+			name: "multi-entry shift",
+			shifts: []angelapi.Shift{
+				testShift(1, "Late", at(18, 0), at(20, 0),
+					testEntry("Bar", 2, 3, 4),
+					testEntry("Cashier", 1, 5),
+					testEntry("Runner", 1, 3),
+				),
+			},
+			want: Diff{
+				UsersLeaving: []User{},
+				UsersWorking: []User{},
+				UsersArriving: []User{
+					testUser(3, "Bar", "Late"),
+					testUser(4, "Bar", "Late"),
+					testUser(5, "Cashier", "Late"),
+				},
+				ExpectedUsers: 4,
+				OpenUsers:     map[string]int64{},
+			},
+		},
+		{
+			name: "open slots",
+			shifts: []angelapi.Shift{
+				testShift(1, "Late", at(18, 0), at(20, 0),
+					testEntry("Bar", 3, 3),
+					testEntry("Cashier", 1),
+					testEntry("Deco", 1, 6, 7),
+				),
+				testShift(2, "Late Extra", at(18, 0), at(19, 0), testEntry("Bar", 1)),
+				testShift(3, "Night", at(20, 0), at(22, 0), testEntry("Bar", 5)),
+			},
+			want: Diff{
+				UsersLeaving: []User{},
+				UsersWorking: []User{},
+				UsersArriving: []User{
+					testUser(3, "Bar", "Late"),
+					testUser(6, "Deco", "Late"),
+					testUser(7, "Deco", "Late"),
+				},
+				ExpectedUsers: 6,
+				OpenUsers:     map[string]int64{"Bar": 3, "Cashier": 1},
+			},
+		},
+		{
+			name: "past and far future shifts",
+			shifts: []angelapi.Shift{
+				testShift(1, "Past", at(14, 0), at(16, 0), testEntry("Bar", 1, 1)),
+				testShift(2, "Future", at(19, 0), at(21, 0), testEntry("Bar", 1, 2)),
+			},
+			want: Diff{
+				UsersLeaving:  []User{},
+				UsersWorking:  []User{},
+				UsersArriving: []User{},
+				OpenUsers:     map[string]int64{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute(tt.shifts, testShiftStart.Add(-time.Minute*15), time.Minute*15)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compute() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenPositions(t *testing.T) {
+	tests := []struct {
+		name  string
+		shift angelapi.Shift
+		want  map[string]int64
+	}{
+		{
+			name:  "fully staffed",
+			shift: testShift(1, "Late", testShiftStart, testShiftStart.Add(time.Hour), testEntry("Bar", 2, 1, 2)),
+			want:  map[string]int64{},
+		},
+		{
+			name:  "overstaffed entries do not count",
+			shift: testShift(1, "Late", testShiftStart, testShiftStart.Add(time.Hour), testEntry("Bar", 1, 1, 2), testEntry("Cashier", 2)),
+			want:  map[string]int64{"Cashier": 2},
+		},
+		{
+			name:  "entries of the same angel type add up",
+			shift: testShift(1, "Late", testShiftStart, testShiftStart.Add(time.Hour), testEntry("Bar", 2, 1), testEntry("Bar", 3, 2)),
+			want:  map[string]int64{"Bar": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := OpenPositions(tt.shift)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OpenPositions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package shiftnotifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+
+	_ "embed"
+)
+
+//go:embed openapi/v1.yaml
+var openAPIDocument []byte
+
+const (
+	// apiDefaultShiftHours defines how far upcoming shifts are listed by
+	// default.
+	apiDefaultShiftHours = 3
+	apiMaxShiftHours     = 48
+	// apiShiftLookupWindow defines how far around now shifts are searched
+	// when requested by ID.
+	apiShiftLookupWindow = time.Hour * 24
+	apiTimeout           = time.Second * 30
+)
+
+// The API types are the stable representation of the data, internal types
+// must not be exposed.
+
+type apiResponse struct {
+	Meta apiMeta `json:"meta"`
+	Data any     `json:"data"`
+}
+
+type apiMeta struct {
+	// ReferenceTime is the time the data was computed for.
+	ReferenceTime time.Time `json:"reference_time"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	// Stale is set if the Engelsystem was down and cached data was used.
+	Stale bool `json:"stale"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiLocation struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type apiDiff struct {
+	ShiftStart time.Time         `json:"shift_start"`
+	Locations  []apiLocationDiff `json:"locations"`
+}
+
+type apiLocationDiff struct {
+	Location      string            `json:"location"`
+	Arriving      []apiDiffUser     `json:"arriving"`
+	Staying       []apiDiffUser     `json:"staying"`
+	Leaving       []apiDiffUser     `json:"leaving"`
+	ExpectedUsers int64             `json:"expected_users"`
+	OpenPositions []apiOpenPosition `json:"open_positions"`
+}
+
+type apiDiffUser struct {
+	ID        int64  `json:"id"`
+	Nickname  string `json:"nickname"`
+	AngelType string `json:"angel_type"`
+	ShiftName string `json:"shift_name"`
+	CheckedIn bool   `json:"checked_in"`
+}
+
+type apiOpenPosition struct {
+	AngelType string `json:"angel_type"`
+	Open      int64  `json:"open"`
+}
+
+type apiShift struct {
+	ID            int64             `json:"id"`
+	Title         string            `json:"title"`
+	Description   string            `json:"description"`
+	StartsAt      time.Time         `json:"starts_at"`
+	EndsAt        time.Time         `json:"ends_at"`
+	Location      apiLocation       `json:"location"`
+	Entries       []apiShiftEntry   `json:"entries"`
+	OpenPositions []apiOpenPosition `json:"open_positions"`
+}
+
+type apiShiftEntry struct {
+	AngelType string         `json:"angel_type"`
+	Needs     int64          `json:"needs"`
+	Users     []apiShiftUser `json:"users"`
+}
+
+type apiShiftUser struct {
+	ID       int64  `json:"id"`
+	Nickname string `json:"nickname"`
+	// Contact is only set for tokens with full data scope.
+	Contact *apiContact `json:"contact,omitempty"`
+}
+
+type apiContact struct {
+	DECT   string `json:"dect"`
+	Mobile string `json:"mobile"`
+}
+
+// apiHandler handles an authorized API request.
+type apiHandler func(w http.ResponseWriter, r *http.Request, token *HTTPToken)
+
+// registerAPI registers the handlers of the versioned API.
+func (service *service) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/openapi.yaml", service.serveOpenAPI)
+	mux.HandleFunc("GET /api/v1/locations", service.requireAPIToken(service.serveAPILocations))
+	mux.HandleFunc("GET /api/v1/diff", service.requireAPIToken(service.serveAPIDiff))
+	mux.HandleFunc("GET /api/v1/shifts", service.requireAPIToken(service.serveAPIShifts))
+	mux.HandleFunc("GET /api/v1/shifts/{id}", service.requireAPIToken(service.serveAPIShift))
+}
+
+func (service *service) requireAPIToken(handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := service.requireToken(r, ScopeDisplayOnly)
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		handler(w, r, token)
+	}
+}
+
+func (service *service) serveOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPIDocument)
+}
+
+func (service *service) serveAPILocations(w http.ResponseWriter, r *http.Request, _ *HTTPToken) {
+	ctx, cancel := context.WithTimeout(r.Context(), apiTimeout)
+	defer cancel()
+
+	freshness := &angelapi.Freshness{}
+	ctx = angelapi.WithFreshness(ctx, freshness)
+
+	now := time.Now()
+	locations, err := service.getLocations(ctx)
+	if err != nil {
+		writeAPIUpstreamError(w, err)
+		return
+	}
+
+	data := make([]apiLocation, 0, len(locations))
+	for _, location := range locations {
+		data = append(data, toAPILocation(location))
+	}
+
+	writeAPIResponse(w, apiMeta{ReferenceTime: now, Stale: freshness.Stale()}, data)
+}
+
+func (service *service) serveAPIDiff(w http.ResponseWriter, _ *http.Request, _ *HTTPToken) {
+	diffs := service.latestDiffs.Load()
+	if diffs == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "no data yet")
+		return
+	}
+
+	writeAPIResponse(w, apiMeta{ReferenceTime: diffs.ReferenceTime, Stale: diffs.Stale}, toAPIDiff(diffs))
+}
+
+func (service *service) serveAPIShifts(w http.ResponseWriter, r *http.Request, token *HTTPToken) {
+	hours := apiDefaultShiftHours
+	if hoursParam := r.URL.Query().Get("hours"); hoursParam != "" {
+		parsed, err := strconv.Atoi(hoursParam)
+		if err != nil || parsed < 1 || parsed > apiMaxShiftHours {
+			writeAPIError(w, http.StatusBadRequest, "hours must be between 1 and "+strconv.Itoa(apiMaxShiftHours))
+			return
+		}
+		hours = parsed
+	}
+
+	now := time.Now()
+	shifts, stale, err := service.listAPIShifts(r.Context(), now, now.Add(time.Duration(hours)*time.Hour))
+	if err != nil {
+		writeAPIUpstreamError(w, err)
+		return
+	}
+
+	data := make([]apiShift, 0, len(shifts))
+	for _, shift := range shifts {
+		if shift.StartsAt.After(now) {
+			data = append(data, toAPIShift(shift, token.Scope.includes(ScopeFullData)))
+		}
+	}
+
+	writeAPIResponse(w, apiMeta{ReferenceTime: now, Stale: stale}, data)
+}
+
+func (service *service) serveAPIShift(w http.ResponseWriter, r *http.Request, token *HTTPToken) {
+	shiftID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid shift ID")
+		return
+	}
+
+	now := time.Now()
+	shifts, stale, err := service.listAPIShifts(r.Context(), now.Add(-apiShiftLookupWindow), now.Add(apiShiftLookupWindow))
+	if err != nil {
+		writeAPIUpstreamError(w, err)
+		return
+	}
+
+	for _, shift := range shifts {
+		if shift.ID == shiftID {
+			writeAPIResponse(w, apiMeta{ReferenceTime: now, Stale: stale}, toAPIShift(shift, token.Scope.includes(ScopeFullData)))
+			return
+		}
+	}
+
+	writeAPIError(w, http.StatusNotFound, "shift not found")
+}
+
+// listAPIShifts returns the shifts of all configured locations within the
+// time range, ordered by start time.
+func (service *service) listAPIShifts(ctx context.Context, from, until time.Time) ([]angelapi.Shift, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+
+	freshness := &angelapi.Freshness{}
+	ctx = angelapi.WithFreshness(ctx, freshness)
+
+	locations, err := service.getLocations(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Fetch directly instead of via listShiftsInLocations, API requests
+	// should not create shift snapshots and partial data is an error.
+	shifts := []angelapi.Shift{}
+	for _, location := range locations {
+		shiftsInLocation, err := service.angelAPI.ListShiftsInLocation(ctx, location.ID, &angelapi.ListShiftsInLocationOpts{
+			From:  from,
+			Until: until,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		shifts = append(shifts, shiftsInLocation...)
+	}
+	sort.SliceStable(shifts, func(i, j int) bool {
+		return shifts[i].StartsAt.Before(shifts[j].StartsAt)
+	})
+
+	return shifts, freshness.Stale(), nil
+}
+
+func toAPILocation(location angelapi.Location) apiLocation {
+	return apiLocation{
+		ID:   location.ID,
+		Name: location.Name,
+	}
+}
+
+func toAPIDiff(diffs *shiftDiffs) apiDiff {
+	data := apiDiff{
+		ShiftStart: diffs.ShiftStart,
+		Locations:  make([]apiLocationDiff, 0, len(diffs.DiffsInLocations)),
+	}
+
+	for _, location := range sortedLocations(diffs) {
+		diff := diffs.DiffsInLocations[location]
+		data.Locations = append(data.Locations, apiLocationDiff{
+			Location:      location,
+			Arriving:      toAPIDiffUsers(diff.UsersArriving),
+			Staying:       toAPIDiffUsers(diff.UsersWorking),
+			Leaving:       toAPIDiffUsers(diff.UsersLeaving),
+			ExpectedUsers: diff.ExpectedUsers,
+			OpenPositions: toAPIOpenPositions(diff.OpenUsers),
+		})
+	}
+
+	return data
+}
+
+func toAPIDiffUsers(users []shiftdiff.User) []apiDiffUser {
+	data := make([]apiDiffUser, 0, len(users))
+	for _, user := range users {
+		data = append(data, apiDiffUser{
+			ID:        user.ID,
+			Nickname:  user.Nickname,
+			AngelType: user.AngelType,
+			ShiftName: user.ShiftName,
+			CheckedIn: user.CheckedIn,
+		})
+	}
+
+	return data
+}
+
+func toAPIOpenPositions(openUsers map[string]int64) []apiOpenPosition {
+	data := make([]apiOpenPosition, 0, len(openUsers))
+	for angelType, open := range openUsers {
+		data = append(data, apiOpenPosition{
+			AngelType: angelType,
+			Open:      open,
+		})
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].AngelType < data[j].AngelType
+	})
+
+	return data
+}
+
+func toAPIShift(shift angelapi.Shift, withContact bool) apiShift {
+	data := apiShift{
+		ID:            shift.ID,
+		Title:         shift.Title,
+		Description:   shift.Description,
+		StartsAt:      shift.StartsAt,
+		EndsAt:        shift.EndsAt,
+		Location:      toAPILocation(shift.Location),
+		Entries:       make([]apiShiftEntry, 0, len(shift.Entries)),
+		OpenPositions: toAPIOpenPositions(shiftdiff.OpenPositions(shift)),
+	}
+
+	for _, entry := range shift.Entries {
+		users := make([]apiShiftUser, 0, len(entry.Users))
+		for _, user := range entry.Users {
+			apiUser := apiShiftUser{
+				ID:       user.ID,
+				Nickname: user.NickName,
+			}
+			if withContact {
+				apiUser.Contact = &apiContact{
+					DECT:   user.Contact.DECT,
+					Mobile: user.Contact.Mobile,
+				}
+			}
+			users = append(users, apiUser)
+		}
+
+		data.Entries = append(data.Entries, apiShiftEntry{
+			AngelType: entry.Type.Name,
+			Needs:     entry.Needs,
+			Users:     users,
+		})
+	}
+
+	return data
+}
+
+func writeAPIResponse(w http.ResponseWriter, meta apiMeta, data any) {
+	meta.GeneratedAt = time.Now()
+	writeAPIJSON(w, http.StatusOK, &apiResponse{
+		Meta: meta,
+		Data: data,
+	})
+}
+
+func writeAPIError(w http.ResponseWriter, statusCode int, msg string) {
+	writeAPIJSON(w, statusCode, &apiError{Error: msg})
+}
+
+// writeAPIUpstreamError answers with an error matching the failed
+// Engelsystem request.
+func writeAPIUpstreamError(w http.ResponseWriter, err error) {
+	slog.Error("failed to serve API request", "error", err.Error())
+
+	switch {
+	case errors.Is(err, angelapi.ErrServerUnavailable), errors.Is(err, angelapi.ErrRateLimited):
+		writeAPIError(w, http.StatusServiceUnavailable, "Engelsystem unavailable")
+	default:
+		writeAPIError(w, http.StatusBadGateway, "Engelsystem request failed")
+	}
+}
+
+func writeAPIJSON(w http.ResponseWriter, statusCode int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("failed marshaling data", "error", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,284 @@
+package shiftnotifier
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenScope defines what a HTTP token grants access to.
+type TokenScope string
+
+// Available token scopes, full data includes contact info of trolls.
+const (
+	ScopeDisplayOnly TokenScope = "display-only"
+	ScopeFullData    TokenScope = "full-data"
+)
+
+// HTTPToken is a named token to access the HTTP server.
+type HTTPToken struct {
+	Name  string
+	Scope TokenScope
+	Token string
+}
+
+const (
+	sessionCookieName = "trollinfo_session"
+	// authLinkValidity defines how long one-time links can be exchanged.
+	authLinkValidity = time.Minute * 10
+)
+
+var errUnauthorized = errors.New("invalid auth")
+
+// includes reports whether the scope grants everything the other scope does.
+func (scope TokenScope) includes(other TokenScope) bool {
+	return scope == other || scope == ScopeFullData
+}
+
+func (scope TokenScope) valid() bool {
+	return scope == ScopeDisplayOnly || scope == ScopeFullData
+}
+
+// authenticate returns the token the request is authorized with. Tokens are
+// accepted as bearer token, session cookie or, deprecated, query parameter.
+func (service *service) authenticate(r *http.Request) (*HTTPToken, error) {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return service.findToken(strings.TrimSpace(bearer))
+	}
+
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		token, err := service.verifySession(cookie.Value)
+		if err == nil {
+			return token, nil
+		}
+	}
+
+	if t := r.URL.Query().Get("token"); t != "" {
+		token, err := service.findToken(strings.TrimSpace(t))
+		if err == nil {
+			slog.Warn("token passed as query parameter, this is deprecated, use a bearer token or one-time link instead", "token", token.Name, "path", r.URL.Path)
+		}
+		return token, err
+	}
+
+	return nil, errUnauthorized
+}
+
+// requireToken checks the request is authorized with at least the scope.
+func (service *service) requireToken(r *http.Request, scope TokenScope) (*HTTPToken, error) {
+	token, err := service.authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Scope.includes(scope) {
+		return nil, errUnauthorized
+	}
+
+	return token, nil
+}
+
+// findToken compares the value with all configured tokens in constant time.
+func (service *service) findToken(value string) (*HTTPToken, error) {
+	var found *HTTPToken
+	for i := range service.config.Tokens {
+		token := &service.config.Tokens[i]
+		if subtle.ConstantTimeCompare([]byte(token.Token), []byte(value)) == 1 && found == nil {
+			found = token
+		}
+	}
+	if found == nil || value == "" {
+		return nil, errUnauthorized
+	}
+
+	return found, nil
+}
+
+// Sessions are stateless so they survive restarts, the cookie holds the
+// token name, scope and expiry signed with the token. Changing or removing
+// the token revokes its sessions.
+
+func (service *service) newSession(token *HTTPToken, scope TokenScope) (string, time.Time) {
+	expiresAt := time.Now().Add(service.config.SessionDuration)
+	payload := strconv.FormatInt(expiresAt.Unix(), 10) + "." + string(scope) + "." + token.Name
+
+	return signSession(token, payload) + "." + payload, expiresAt
+}
+
+func (service *service) verifySession(value string) (*HTTPToken, error) {
+	signature, payload, _ := strings.Cut(value, ".")
+	parts := strings.SplitN(payload, ".", 3)
+	if len(parts) != 3 {
+		return nil, errUnauthorized
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, errUnauthorized
+	}
+
+	for i := range service.config.Tokens {
+		token := &service.config.Tokens[i]
+		if token.Name != parts[2] || token.Token == "" {
+			continue
+		}
+		if !hmac.Equal([]byte(signSession(token, payload)), []byte(signature)) {
+			return nil, errUnauthorized
+		}
+
+		scope := TokenScope(parts[1])
+		if !token.Scope.includes(scope) {
+			return nil, errUnauthorized
+		}
+
+		return &HTTPToken{Name: token.Name, Scope: scope, Token: token.Token}, nil
+	}
+
+	return nil, errUnauthorized
+}
+
+func signSession(token *HTTPToken, payload string) string {
+	mac := hmac.New(sha256.New, []byte(token.Token))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// authLink is a one-time link that is exchanged for a session cookie.
+type authLink struct {
+	token     *HTTPToken
+	scope     TokenScope
+	next      string
+	expiresAt time.Time
+}
+
+// authLinks holds the links not exchanged yet, accessed by the HTTP handlers.
+type authLinks struct {
+	mutex sync.Mutex
+	links map[string]authLink
+}
+
+func newAuthLinks() *authLinks {
+	return &authLinks{
+		links: make(map[string]authLink),
+	}
+}
+
+func (links *authLinks) add(link authLink) (string, error) {
+	code := make([]byte, 32)
+	_, err := rand.Read(code)
+	if err != nil {
+		return "", err
+	}
+
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	now := time.Now()
+	for key, existing := range links.links {
+		if now.After(existing.expiresAt) {
+			delete(links.links, key)
+		}
+	}
+
+	key := hex.EncodeToString(code)
+	links.links[key] = link
+	return key, nil
+}
+
+// take removes the link and returns it if it is still valid.
+func (links *authLinks) take(code string) (authLink, bool) {
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	link, ok := links.links[code]
+	if !ok {
+		return authLink{}, false
+	}
+	delete(links.links, code)
+
+	return link, time.Now().Before(link.expiresAt)
+}
+
+type apiAuthLink struct {
+	URL       string     `json:"url"`
+	Scope     TokenScope `json:"scope"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// serveCreateAuthLink creates a one-time link for kiosk screens, optionally
+// with a lower scope than the requesting token.
+func (service *service) serveCreateAuthLink(w http.ResponseWriter, r *http.Request) {
+	token, err := service.requireToken(r, ScopeDisplayOnly)
+	if err != nil {
+		writeAPIError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	scope := token.Scope
+	if requested := r.URL.Query().Get("scope"); requested != "" {
+		scope = TokenScope(requested)
+		if !scope.valid() || !token.Scope.includes(scope) {
+			writeAPIError(w, http.StatusForbidden, "scope not allowed")
+			return
+		}
+	}
+
+	next := r.URL.Query().Get("next")
+	if !isLocalPath(next) {
+		next = "/landscape"
+	}
+
+	link := authLink{
+		token:     token,
+		scope:     scope,
+		next:      next,
+		expiresAt: time.Now().Add(authLinkValidity),
+	}
+	code, err := service.authLinks.add(link)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to create link")
+		return
+	}
+
+	writeAPIJSON(w, http.StatusCreated, &apiAuthLink{
+		URL:       "/auth/exchange?code=" + code,
+		Scope:     scope,
+		ExpiresAt: link.expiresAt,
+	})
+}
+
+// serveExchangeAuthLink sets the session cookie for a one-time link and
+// redirects to the linked page.
+func (service *service) serveExchangeAuthLink(w http.ResponseWriter, r *http.Request) {
+	link, ok := service.authLinks.take(r.URL.Query().Get("code"))
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("link invalid or expired"))
+		return
+	}
+
+	value, expiresAt := service.newSession(link.token, link.scope)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, link.next, http.StatusSeeOther)
+}
+
+// isLocalPath prevents redirects to other hosts.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.HasPrefix(path, "/\\")
+}
@@ -0,0 +1,270 @@
+package shiftnotifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTokens = []HTTPToken{
+	{Name: "orga", Scope: ScopeFullData, Token: "full-secret"},
+	{Name: "screen", Scope: ScopeDisplayOnly, Token: "display-secret"},
+}
+
+func newAuthTestService(tokens []HTTPToken, sessionDuration time.Duration) *service {
+	return &service{
+		config: &Config{
+			Tokens:          tokens,
+			SessionDuration: sessionDuration,
+		},
+		authLinks: newAuthLinks(),
+	}
+}
+
+func TestFindToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		tokens   []HTTPToken
+		value    string
+		wantName string
+	}{
+		{name: "full data", tokens: testTokens, value: "full-secret", wantName: "orga"},
+		{name: "display only", tokens: testTokens, value: "display-secret", wantName: "screen"},
+		{name: "empty value", tokens: testTokens, value: ""},
+		{name: "empty value with empty token", tokens: []HTTPToken{{Name: "unset", Scope: ScopeFullData}}, value: ""},
+		{name: "no match", tokens: testTokens, value: "full-secret2"},
+		{name: "prefix", tokens: testTokens, value: "full"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newAuthTestService(tt.tokens, time.Hour)
+
+			token, err := service.findToken(tt.value)
+			if tt.wantName == "" {
+				if err != errUnauthorized {
+					t.Errorf("findToken() = %v, %v, want %v", token, err, errUnauthorized)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findToken() error = %v", err)
+			}
+			if token.Name != tt.wantName {
+				t.Errorf("findToken() = %s, want %s", token.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestVerifySession(t *testing.T) {
+	full := &testTokens[0]
+	display := &testTokens[1]
+
+	tests := []struct {
+		name            string
+		session         func(*service) string
+		tokens          []HTTPToken
+		sessionDuration time.Duration
+		wantScope       TokenScope
+	}{
+		{
+			name:      "valid",
+			session:   func(s *service) string { value, _ := s.newSession(full, ScopeFullData); return value },
+			wantScope: ScopeFullData,
+		},
+		{
+			name:      "downgraded scope",
+			session:   func(s *service) string { value, _ := s.newSession(full, ScopeDisplayOnly); return value },
+			wantScope: ScopeDisplayOnly,
+		},
+		{
+			name: "tampered signature",
+			session: func(s *service) string {
+				value, _ := s.newSession(full, ScopeFullData)
+				if value[0] == '0' {
+					return "1" + value[1:]
+				}
+				return "0" + value[1:]
+			},
+		},
+		{
+			name: "tampered scope",
+			session: func(s *service) string {
+				value, _ := s.newSession(display, ScopeDisplayOnly)
+				return strings.Replace(value, string(ScopeDisplayOnly), string(ScopeFullData), 1)
+			},
+		},
+		{
+			name:            "expired",
+			session:         func(s *service) string { value, _ := s.newSession(full, ScopeFullData); return value },
+			sessionDuration: -time.Minute,
+		},
+		{
+			name:    "scope higher than token",
+			session: func(s *service) string { value, _ := s.newSession(display, ScopeFullData); return value },
+		},
+		{
+			name:    "renamed token",
+			session: func(s *service) string { value, _ := s.newSession(full, ScopeFullData); return value },
+			tokens:  []HTTPToken{{Name: "orga-renamed", Scope: ScopeFullData, Token: "full-secret"}},
+		},
+		{
+			name:    "removed token",
+			session: func(s *service) string { value, _ := s.newSession(full, ScopeFullData); return value },
+			tokens:  []HTTPToken{*display},
+		},
+		{
+			name:    "changed token",
+			session: func(s *service) string { value, _ := s.newSession(full, ScopeFullData); return value },
+			tokens:  []HTTPToken{{Name: "orga", Scope: ScopeFullData, Token: "rotated-secret"}},
+		},
+		{
+			name:    "malformed",
+			session: func(*service) string { return "signature-only" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionDuration := tt.sessionDuration
+			if sessionDuration == 0 {
+				sessionDuration = time.Hour
+			}
+			value := tt.session(newAuthTestService(testTokens, sessionDuration))
+
+			// The session is verified against the current configuration.
+			tokens := tt.tokens
+			if tokens == nil {
+				tokens = testTokens
+			}
+			token, err := newAuthTestService(tokens, time.Hour).verifySession(value)
+			if tt.wantScope == "" {
+				if err != errUnauthorized {
+					t.Errorf("verifySession() = %+v, %v, want %v", token, err, errUnauthorized)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifySession() error = %v", err)
+			}
+			if token.Scope != tt.wantScope {
+				t.Errorf("verifySession() scope = %s, want %s", token.Scope, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestServeCreateAuthLink(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		scope      string
+		wantStatus int
+		wantScope  TokenScope
+	}{
+		{name: "token scope", token: "full-secret", wantStatus: http.StatusCreated, wantScope: ScopeFullData},
+		{name: "downgrade", token: "full-secret", scope: "display-only", wantStatus: http.StatusCreated, wantScope: ScopeDisplayOnly},
+		{name: "same scope", token: "display-secret", scope: "display-only", wantStatus: http.StatusCreated, wantScope: ScopeDisplayOnly},
+		{name: "escalation", token: "display-secret", scope: "full-data", wantStatus: http.StatusForbidden},
+		{name: "unknown scope", token: "full-secret", scope: "admin", wantStatus: http.StatusForbidden},
+		{name: "invalid token", token: "wrong", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newAuthTestService(testTokens, time.Hour)
+
+			r := httptest.NewRequest(http.MethodPost, "/auth/links?scope="+tt.scope, nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			service.serveCreateAuthLink(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if len(service.authLinks.links) != 0 {
+					t.Errorf("%d links created, want none", len(service.authLinks.links))
+				}
+				return
+			}
+
+			link := apiAuthLink{}
+			err := json.Unmarshal(w.Body.Bytes(), &link)
+			if err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if link.Scope != tt.wantScope {
+				t.Errorf("link scope = %s, want %s", link.Scope, tt.wantScope)
+			}
+
+			// The session created by the link has the scope of the link.
+			r = httptest.NewRequest(http.MethodGet, link.URL, nil)
+			w = httptest.NewRecorder()
+			service.serveExchangeAuthLink(w, r)
+			if w.Code != http.StatusSeeOther {
+				t.Fatalf("exchange status = %d, want %d", w.Code, http.StatusSeeOther)
+			}
+			token, err := service.verifySession(w.Result().Cookies()[0].Value)
+			if err != nil || token.Scope != tt.wantScope {
+				t.Errorf("session = %+v, %v, want scope %s", token, err, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestAuthLinksTake(t *testing.T) {
+	links := newAuthLinks()
+
+	valid, err := links.add(authLink{token: &testTokens[0], scope: ScopeFullData, expiresAt: time.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	expired, err := links.add(authLink{token: &testTokens[0], scope: ScopeFullData, expiresAt: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+
+	if _, ok := links.take(valid); !ok {
+		t.Error("take() of valid link failed")
+	}
+	if _, ok := links.take(valid); ok {
+		t.Error("take() of used link succeeded")
+	}
+	if _, ok := links.take(expired); ok {
+		t.Error("take() of expired link succeeded")
+	}
+	if _, ok := links.take(""); ok {
+		t.Error("take() of empty code succeeded")
+	}
+	if len(links.links) != 0 {
+		t.Errorf("%d links left, want none", len(links.links))
+	}
+}
+
+func TestIsLocalPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/landscape", want: true},
+		{path: "/landscape?location=1", want: true},
+		{path: "", want: false},
+		{path: "landscape", want: false},
+		{path: "//evil.example.org", want: false},
+		{path: "/\\evil.example.org", want: false},
+		{path: "https://evil.example.org", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := isLocalPath(tt.path); got != tt.want {
+				t.Errorf("isLocalPath(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package shiftnotifier
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+)
+
+// changeTracker holds the state of the change detection. It is only accessed
+// by the change detection job.
+type changeTracker struct {
+	// baselines hold the last announced state per location.
+	baselines map[int64][]angelapi.Shift
+	// pending holds when a not yet announced change was first seen.
+	pending map[string]time.Time
+}
+
+func newChangeTracker() *changeTracker {
+	return &changeTracker{
+		baselines: make(map[int64][]angelapi.Shift),
+		pending:   make(map[string]time.Time),
+	}
+}
+
+type locationChanges struct {
+	Location angelapi.Location
+	Changes  []shiftdiff.Change
+}
+
+// detectChanges compares the upcoming shifts with the last announced state
+// and posts changes that persisted for the debounce window.
+func (service *service) detectChanges() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	locations, err := service.getLocations(ctx)
+	if err != nil {
+		slog.Error("failed to list locations", "error", err.Error())
+		return
+	}
+
+	// Load the snapshots before fetching, as fetching stores new ones.
+	for _, location := range locations {
+		if _, ok := service.changes.baselines[location.ID]; ok {
+			continue
+		}
+
+		snapshot, err := service.store.LatestShiftSnapshot(ctx, location.ID)
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				slog.Error("failed to load shift snapshot", "location_id", location.ID, "error", err.Error())
+			}
+			continue
+		}
+		service.changes.baselines[location.ID] = snapshot.Shifts
+	}
+
+	now := time.Now()
+	shifts := service.listShiftsInLocations(ctx, locations, &angelapi.ListShiftsInLocationOpts{
+		From:  now,
+		Until: now.Add(planAhead),
+	})
+
+	allChanges := []locationChanges{}
+	seenKeys := map[string]bool{}
+	for i, location := range locations {
+		if shifts[i] == nil {
+			continue
+		}
+
+		baseline, ok := service.changes.baselines[location.ID]
+		if !ok {
+			service.changes.baselines[location.ID] = shifts[i]
+			continue
+		}
+		// Changes are applied to the baseline, do not modify shared snapshots.
+		baseline = slices.Clone(baseline)
+
+		stableChanges := []shiftdiff.Change{}
+		for _, change := range shiftdiff.CompareShifts(baseline, shifts[i]) {
+			key := strconv.FormatInt(location.ID, 10) + "/" + change.Key()
+			seenKeys[key] = true
+
+			firstSeen, ok := service.changes.pending[key]
+			if !ok {
+				service.changes.pending[key] = now
+				firstSeen = now
+			}
+			if now.Sub(firstSeen) < service.config.ChangeDebounce {
+				continue
+			}
+
+			stableChanges = append(stableChanges, change)
+			shiftdiff.Apply(baseline, &change)
+			delete(service.changes.pending, key)
+		}
+
+		service.changes.baselines[location.ID] = mergeBaseline(baseline, shifts[i])
+		if len(stableChanges) > 0 {
+			allChanges = append(allChanges, locationChanges{
+				Location: location,
+				Changes:  stableChanges,
+			})
+		}
+	}
+
+	// Changes that vanished again were flapping.
+	for key := range service.changes.pending {
+		if !seenKeys[key] {
+			delete(service.changes.pending, key)
+		}
+	}
+
+	if len(allChanges) == 0 {
+		return
+	}
+
+	msg, msgFormatted := changesToMessage(allChanges)
+	_, err = service.messenger.SendMessage(ctx, matrixmessenger.HTMLMessage(
+		msg, msgFormatted, service.config.MatrixRoomID,
+	))
+	if err != nil {
+		slog.Error("failed to send changes", "error", err.Error())
+	}
+
+	// Update the shift message right away instead of on the next refresh.
+	service.refreshCurrentDiffs()
+}
+
+// mergeBaseline returns the baseline for the current shifts, keeping the
+// baseline state of known shifts and dropping the ones no longer fetched.
+func mergeBaseline(baseline, current []angelapi.Shift) []angelapi.Shift {
+	merged := make([]angelapi.Shift, 0, len(current))
+	for _, shift := range current {
+		for _, baselineShift := range baseline {
+			if baselineShift.ID == shift.ID {
+				shift.Entries = baselineShift.Entries
+				break
+			}
+		}
+		merged = append(merged, shift)
+	}
+
+	return merged
+}
+
+func changesToMessage(allChanges []locationChanges) (string, string) {
+	msg := strings.Builder{}
+	msgHTML := strings.Builder{}
+
+	msg.WriteString("TROLL SHIFT CHANGES\n\n")
+	msgHTML.WriteString("<h1>Troll Shift Changes</h1><br>\n")
+
+	for _, location := range allChanges {
+		msg.WriteString("📍 ")
+		msg.WriteString(location.Location.Name)
+		msg.WriteString("\n")
+
+		msgHTML.WriteString("📍 <b>")
+		msgHTML.WriteString(location.Location.Name)
+		msgHTML.WriteString("</b><br>\n")
+
+		for _, change := range location.Changes {
+			line := changeToLine(&change)
+			msg.WriteString("  ")
+			msg.WriteString(line)
+			msg.WriteString("\n")
+
+			msgHTML.WriteString("&nbsp;&nbsp;")
+			msgHTML.WriteString(line)
+			msgHTML.WriteString("<br>\n")
+		}
+
+		msg.WriteString("\n")
+		msgHTML.WriteString("<br>\n")
+	}
+
+	return msg.String(), msgHTML.String()
+}
+
+func changeToLine(change *shiftdiff.Change) string {
+	line := strings.Builder{}
+	switch {
+	case change.IsNeedsChange() && change.NeedsAfter > change.NeedsBefore:
+		line.WriteString("📈 ")
+	case change.IsNeedsChange():
+		line.WriteString("📉 ")
+	case change.Joined:
+		line.WriteString("➕ ")
+	default:
+		line.WriteString("➖ ")
+	}
+
+	if change.IsNeedsChange() {
+		line.WriteString(change.AngelType)
+		line.WriteString(shiftNameToEmoji(change.AngelType))
+		line.WriteString(" needed ")
+		line.WriteString(strconv.Itoa(int(change.NeedsBefore)))
+		line.WriteString(" → ")
+		line.WriteString(strconv.Itoa(int(change.NeedsAfter)))
+	} else {
+		line.WriteString(change.User.Nickname)
+		if change.Joined {
+			line.WriteString(" joined as ")
+		} else {
+			line.WriteString(" left as ")
+		}
+		line.WriteString(change.AngelType)
+		line.WriteString(shiftNameToEmoji(change.AngelType))
+	}
+
+	line.WriteString(" in ")
+	line.WriteString(change.ShiftName)
+	line.WriteString(" (")
+	line.WriteString(change.ShiftStartsAt.In(defaultTimeZone()).Format("Mon, 15:04"))
+	line.WriteString(")")
+
+	return line.String()
+}
@@ -0,0 +1,147 @@
+package shiftnotifier
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/Cubicroots-Playground/trollinfo/internal/fakeengelsystem"
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+)
+
+// changeFixtures serves a single upcoming shift at the Bar Nord staffed by
+// the users set with setUsers.
+type changeFixtures struct {
+	fs       fstest.MapFS
+	startsAt time.Time
+}
+
+func newChangeFixtures() *changeFixtures {
+	fixtures := &changeFixtures{
+		fs: fstest.MapFS{
+			"locations.json": &fstest.MapFile{Data: []byte(`{"data": [{"id": 1, "name": "Bar Nord"}]}`)},
+		},
+		startsAt: time.Now().Add(time.Hour).Truncate(time.Minute).UTC(),
+	}
+	fixtures.setUsers()
+
+	return fixtures
+}
+
+// setUsers replaces the users of the shift, it must not be called while the
+// notifier fetches shifts.
+func (fixtures *changeFixtures) setUsers(ids ...int64) {
+	users := []map[string]any{}
+	for _, id := range ids {
+		users = append(users, map[string]any{"id": id, "name": "Troll " + strconv.FormatInt(id, 10)})
+	}
+
+	raw, _ := json.Marshal(map[string]any{"data": []map[string]any{{
+		"id":        102,
+		"title":     "Bar Nord Abend",
+		"starts_at": fixtures.startsAt.Format(time.RFC3339),
+		"ends_at":   fixtures.startsAt.Add(time.Hour * 2).Format(time.RFC3339),
+		"location":  map[string]any{"id": 1, "name": "Bar Nord"},
+		"entries": []map[string]any{{
+			"type":  map[string]any{"id": 3, "name": "Bar-Theke"},
+			"needs": 2,
+			"users": users,
+		}},
+	}}})
+	fixtures.fs["shifts/1.json"] = &fstest.MapFile{Data: raw}
+}
+
+func newChangeTestService(t *testing.T, fixtures *changeFixtures, debounce time.Duration) (*service, *[]*matrixmessenger.Message) {
+	t.Helper()
+
+	s, messenger := newTestService(t, fakeengelsystem.New(&fakeengelsystem.Config{
+		Fixtures: fixtures.fs,
+	}))
+	s.config.LocationNames = []string{"Bar Nord"}
+	s.config.ChangeDebounce = debounce
+
+	return s, recordMessages(messenger)
+}
+
+func TestDetectChangesDebounce(t *testing.T) {
+	fixtures := newChangeFixtures()
+	fixtures.setUsers(1, 2)
+	s, messages := newChangeTestService(t, fixtures, time.Millisecond*200)
+
+	// The first run only records the baseline.
+	s.detectChanges()
+	if len(*messages) != 0 {
+		t.Fatalf("sent %q for the baseline, want nothing", (*messages)[0].Body)
+	}
+
+	// Flapping changes are not announced.
+	fixtures.setUsers(1)
+	s.detectChanges()
+	fixtures.setUsers(1, 2)
+	time.Sleep(time.Millisecond * 250)
+	s.detectChanges()
+	fixtures.setUsers(1)
+	s.detectChanges()
+	if len(*messages) != 0 {
+		t.Fatalf("sent %q for flapping changes, want nothing", (*messages)[0].Body)
+	}
+
+	// Changes persisting for the debounce window are announced once.
+	time.Sleep(time.Millisecond * 250)
+	s.detectChanges()
+	s.detectChanges()
+	if len(*messages) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(*messages))
+	}
+	if !strings.Contains((*messages)[0].Body, "Troll 2 left as Bar-Theke") {
+		t.Errorf("changes message = %q, want Troll 2 leaving", (*messages)[0].Body)
+	}
+}
+
+func TestDetectChangesRestoresBaseline(t *testing.T) {
+	fixtures := newChangeFixtures()
+	fixtures.setUsers(1, 3)
+	s, messages := newChangeTestService(t, fixtures, 0)
+
+	// The state announced before a restart.
+	err := s.store.SaveShiftSnapshot(context.Background(), &store.ShiftSnapshot{
+		LocationID: 1,
+		FetchedAt:  time.Now().Add(-time.Minute),
+		Shifts: []angelapi.Shift{{
+			ID:       102,
+			Title:    "Bar Nord Abend",
+			StartsAt: fixtures.startsAt,
+			EndsAt:   fixtures.startsAt.Add(time.Hour * 2),
+			Entries: []angelapi.ShiftEntry{{
+				Type:  angelapi.ShiftType{ID: 3, Name: "Bar-Theke"},
+				Needs: 2,
+				Users: []angelapi.User{{ID: 1, NickName: "Troll 1"}, {ID: 2, NickName: "Troll 2"}},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("SaveShiftSnapshot() error = %v", err)
+	}
+
+	s.detectChanges()
+	if len(*messages) != 1 {
+		t.Fatalf("sent %d messages, want the changes since the snapshot", len(*messages))
+	}
+	for _, want := range []string{"Troll 2 left as Bar-Theke", "Troll 3 joined as Bar-Theke"} {
+		if !strings.Contains((*messages)[0].Body, want) {
+			t.Errorf("changes message = %q, want %q", (*messages)[0].Body, want)
+		}
+	}
+
+	// The fetched shifts are the new baseline.
+	s.detectChanges()
+	if len(*messages) != 1 {
+		t.Errorf("sent %d messages, want no further changes", len(*messages))
+	}
+}
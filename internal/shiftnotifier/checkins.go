@@ -0,0 +1,261 @@
+package shiftnotifier
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+	"github.com/go-co-op/gocron/v2"
+)
+
+// checkInReaction is the reaction the bot adds to shift messages for
+// arriving trolls to acknowledge.
+const checkInReaction = "✅"
+
+// checkInTracker holds the shift messages waiting for their follow-up and the
+// trolls that acknowledged them. It is accessed by the matrix listener and the
+// notifier.
+type checkInTracker struct {
+	mutex sync.Mutex
+	// messages are keyed by the reference time in unix seconds.
+	messages map[int64]*trackedMessage
+}
+
+// trackedMessage holds the diffs as sent, the latest diffs move on to the
+// next shift start before the follow-up.
+type trackedMessage struct {
+	eventID string
+	diffs   *shiftDiffs
+	users   map[int64]time.Time
+}
+
+func newCheckInTracker() *checkInTracker {
+	return &checkInTracker{
+		messages: make(map[int64]*trackedMessage),
+	}
+}
+
+// track starts tracking acknowledgements of the message for the diffs,
+// keeping acknowledgements if the message for the reference time is edited.
+func (tracker *checkInTracker) track(diffs *shiftDiffs, eventID string) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	message, ok := tracker.messages[diffs.ReferenceTime.Unix()]
+	if !ok {
+		message = &trackedMessage{
+			users: make(map[int64]time.Time),
+		}
+		tracker.messages[diffs.ReferenceTime.Unix()] = message
+	}
+	message.eventID = eventID
+	message.diffs = diffs
+
+	// Drop messages whose follow-up never ran.
+	for refTime := range tracker.messages {
+		if refTime < diffs.ReferenceTime.Add(-time.Hour*24).Unix() {
+			delete(tracker.messages, refTime)
+		}
+	}
+}
+
+// arrivingUsers returns the arriving trolls of the tracked message.
+func (tracker *checkInTracker) arrivingUsers(eventID string) []shiftdiff.User {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	users := []shiftdiff.User{}
+	for _, message := range tracker.messages {
+		if eventID == "" || message.eventID != eventID {
+			continue
+		}
+		for _, diff := range message.diffs.DiffsInLocations {
+			users = append(users, diff.UsersArriving...)
+		}
+	}
+
+	return users
+}
+
+// checkIn marks the user as checked in if the event ID matches a tracked
+// message.
+func (tracker *checkInTracker) checkIn(eventID string, userID int64) bool {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	for _, message := range tracker.messages {
+		if eventID == "" || message.eventID != eventID {
+			continue
+		}
+		if _, ok := message.users[userID]; ok {
+			return false
+		}
+
+		message.users[userID] = time.Now()
+		return true
+	}
+
+	return false
+}
+
+// apply returns a copy of the diffs with checked in arriving users marked.
+func (tracker *checkInTracker) apply(diffs *shiftDiffs) *shiftDiffs {
+	if diffs == nil {
+		return nil
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	message, ok := tracker.messages[diffs.ReferenceTime.Unix()]
+	if !ok {
+		return diffs
+	}
+
+	return message.mark(diffs)
+}
+
+// take stops tracking the message for the reference time and returns its
+// diffs with checked in arriving users marked.
+func (tracker *checkInTracker) take(refTime time.Time) (*shiftDiffs, bool) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	message, ok := tracker.messages[refTime.Unix()]
+	if !ok {
+		return nil, false
+	}
+	delete(tracker.messages, refTime.Unix())
+
+	return message.mark(message.diffs), true
+}
+
+func (message *trackedMessage) mark(diffs *shiftDiffs) *shiftDiffs {
+	marked := *diffs
+	marked.DiffsInLocations = maps.Clone(diffs.DiffsInLocations)
+	for location, diff := range marked.DiffsInLocations {
+		diff.UsersArriving = slices.Clone(diff.UsersArriving)
+		for i := range diff.UsersArriving {
+			_, ok := message.users[diff.UsersArriving[i].ID]
+			diff.UsersArriving[i].CheckedIn = ok
+		}
+		marked.DiffsInLocations[location] = diff
+	}
+
+	return &marked
+}
+
+// requestCheckIns reacts to new shift messages for trolls to acknowledge and
+// schedules the follow-up for the shift start.
+func (service *service) requestCheckIns(ctx context.Context, diffs *shiftDiffs, eventID string, isNew bool) {
+	if !service.config.CheckInEnabled {
+		return
+	}
+
+	refTime := diffs.ReferenceTime
+	service.checkIns.track(diffs, eventID)
+
+	if isNew {
+		_, err := service.messenger.SendReaction(ctx, checkInReaction, eventID, service.config.MatrixRoomID)
+		if err != nil {
+			slog.Error("failed to add check in reaction", "error", err.Error())
+		}
+	}
+
+	shiftStart := refTime.Add(service.config.NotifyBeforeShiftStart)
+	service.scheduler.RemoveByTags(checkInTag(shiftStart))
+	_, err := service.scheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(shiftStart)),
+		gocron.NewTask(service.followUpCheckIns, refTime, eventID),
+		gocron.WithTags(checkInTag(shiftStart)),
+	)
+	if err != nil {
+		slog.Error("failed to schedule check in follow-up", "shift_start", shiftStart, "error", err.Error())
+	}
+}
+
+// handleCheckIn marks arriving trolls reacting to the shift message as
+// checked in.
+func (service *service) handleCheckIn(ctx context.Context, reaction *matrixmessenger.IncomingReaction) {
+	if !service.config.CheckInEnabled || reaction.ChannelExternalIdentifier != service.config.MatrixRoomID {
+		return
+	}
+
+	for _, user := range service.checkIns.arrivingUsers(reaction.ReactedToMessage) {
+		mapping, err := service.store.GetUserMapping(ctx, user.ID, user.Nickname)
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				slog.Error("failed to load user mapping", "user_id", user.ID, "error", err.Error())
+			}
+			continue
+		}
+		if mapping.MatrixUserID != reaction.Sender {
+			continue
+		}
+
+		if service.checkIns.checkIn(reaction.ReactedToMessage, user.ID) {
+			slog.Info("troll checked in", "user_id", user.ID)
+			service.latestDiffs.Update(service.checkIns.apply)
+		}
+		return
+	}
+}
+
+// followUpCheckIns tells the shift leads about arriving trolls that did not
+// check in until the shift started, based on the diffs of the shift message.
+func (service *service) followUpCheckIns(refTime time.Time, eventID string) {
+	diffs, ok := service.checkIns.take(refTime)
+	if !ok {
+		slog.Warn("shift message not tracked, skipping check in follow-up", "reference_time", refTime)
+		return
+	}
+
+	missing := []string{}
+	for location, diff := range diffs.DiffsInLocations {
+		for _, user := range diff.UsersArriving {
+			if !user.CheckedIn {
+				missing = append(missing, user.Nickname+" ("+user.ShiftName+shiftNameToEmoji(user.ShiftName)+") at "+location)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	sort.Strings(missing)
+
+	msg := "⏰ Not checked in yet:\n- " + strings.Join(missing, "\n- ")
+	msgFormatted := "⏰ Not checked in yet:<br>\n- " + strings.Join(missing, "<br>\n- ")
+	if len(service.config.CheckInLeads) > 0 {
+		mentions := make([]string, 0, len(service.config.CheckInLeads))
+		for _, userID := range service.config.CheckInLeads {
+			mentions = append(mentions, matrixmessenger.GetMatrixLinkForUser(userID))
+		}
+
+		msg += "\n" + strings.Join(service.config.CheckInLeads, " ")
+		msgFormatted += "<br>\n" + strings.Join(mentions, " ")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	message := matrixmessenger.HTMLMessage(msg, msgFormatted, service.config.MatrixRoomID)
+	message.ResponseToMessage = eventID
+
+	_, err := service.messenger.SendMessage(ctx, message)
+	if err != nil {
+		slog.Error("failed to send check in follow-up", "error", err.Error())
+	}
+}
+
+func checkInTag(shiftStart time.Time) string {
+	return "checkin-" + shiftStart.UTC().Format(time.RFC3339)
+}
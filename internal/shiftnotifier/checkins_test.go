@@ -0,0 +1,52 @@
+package shiftnotifier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/golang/mock/gomock"
+)
+
+func TestFollowUpCheckIns(t *testing.T) {
+	s, messenger := newTestService(t, newTestEngelsystem())
+	ctx := context.Background()
+
+	diffs, err := s.computeDiffs(ctx, testRecordedAt.Add(-time.Minute*15))
+	if err != nil {
+		t.Fatalf("computeDiffs() error = %v", err)
+	}
+	s.checkIns.track(diffs, "$first")
+	s.checkIns.checkIn("$first", 3)
+
+	// The next shift message is sent before the follow-up runs.
+	next := &shiftDiffs{
+		DiffsInLocations: map[string]shiftdiff.Diff{},
+		ReferenceTime:    diffs.ReferenceTime.Add(time.Minute * 10),
+	}
+	s.checkIns.track(next, "$second")
+	s.latestDiffs.Store(next)
+
+	messenger.EXPECT().SendMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, message *matrixmessenger.Message) (*matrixmessenger.MessageResponse, error) {
+			if message.ResponseToMessage != "$first" {
+				t.Errorf("follow-up responds to %q, want $first", message.ResponseToMessage)
+			}
+			if !strings.Contains(message.Body, "Troll 4") || strings.Contains(message.Body, "Troll 3") {
+				t.Errorf("follow-up should only mention Troll 4:\n%s", message.Body)
+			}
+			return &matrixmessenger.MessageResponse{ExternalIdentifier: "$followup"}, nil
+		},
+	)
+	s.followUpCheckIns(diffs.ReferenceTime, "$first")
+
+	// The message is no longer tracked, the follow-up is skipped.
+	s.followUpCheckIns(diffs.ReferenceTime, "$first")
+
+	if _, ok := s.checkIns.take(next.ReferenceTime); !ok {
+		t.Error("next shift message should still be tracked")
+	}
+}
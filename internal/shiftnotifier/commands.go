@@ -0,0 +1,221 @@
+package shiftnotifier
+
+import (
+	"context"
+	"html"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+)
+
+// commandPrefix marks messages addressed to the bot.
+const commandPrefix = "!"
+
+type command struct {
+	Description string
+	Usage       string
+	Handle      func(service *service, args string) (string, string)
+}
+
+// commands hold the available commands by name, they are set up in init as
+// the help command lists them.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"next": {
+			Description: "Show arriving, staying and leaving trolls for the next shift change",
+			Handle:      (*service).commandNext,
+		},
+		"now": {
+			Description: "Show the trolls of a single location",
+			Usage:       "<location>",
+			Handle:      (*service).commandNow,
+		},
+		"who": {
+			Description: "Show where a troll is working",
+			Usage:       "<nick>",
+			Handle:      (*service).commandWho,
+		},
+		"open": {
+			Description: "Show open positions in the next shifts",
+			Handle:      (*service).commandOpen,
+		},
+		"help": {
+			Description: "Show this help",
+			Handle:      (*service).commandHelp,
+		},
+	}
+}
+
+// handleCommand answers commands sent to allow-listed rooms in the thread of
+// the command.
+func (service *service) handleCommand(ctx context.Context, message *matrixmessenger.IncomingMessage) {
+	if !slices.Contains(service.config.CommandRoomIDs, message.ChannelExternalIdentifier) {
+		return
+	}
+	if !strings.HasPrefix(message.Body, commandPrefix) {
+		return
+	}
+
+	name, args, _ := strings.Cut(strings.TrimPrefix(message.Body, commandPrefix), " ")
+	cmd, ok := commands[strings.ToLower(name)]
+	if !ok {
+		return
+	}
+
+	slog.Info("received command", "command", name, "sender", message.Sender)
+	msg, msgFormatted := cmd.Handle(service, strings.TrimSpace(args))
+
+	response := matrixmessenger.HTMLMessage(msg, msgFormatted, message.ChannelExternalIdentifier)
+	response.ThreadRootMessage = message.ThreadRootMessage
+	if response.ThreadRootMessage == "" {
+		response.ThreadRootMessage = message.EventID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	_, err := service.messenger.SendMessage(ctx, response)
+	if err != nil {
+		slog.Error("failed to answer command", "command", name, "error", err.Error())
+	}
+}
+
+func (service *service) commandNext(_ string) (string, string) {
+	diffs := service.latestDiffs.Load()
+	if diffs == nil {
+		return noDataMessage()
+	}
+
+	return service.diffToMessage(diffs)
+}
+
+func (service *service) commandNow(args string) (string, string) {
+	diffs := service.latestDiffs.Load()
+	if diffs == nil {
+		return noDataMessage()
+	}
+	if args == "" {
+		return plainMessage("Please name a location, e.g. " + commandPrefix + "now " + strings.Join(sortedLocations(diffs), ", "))
+	}
+
+	for location, diff := range diffs.DiffsInLocations {
+		if strings.EqualFold(location, args) {
+			return service.diffToMessage(&shiftDiffs{
+				DiffsInLocations: map[string]shiftdiff.Diff{location: diff},
+				ReferenceTime:    diffs.ReferenceTime,
+				ShiftStart:       diffs.ShiftStart,
+				Stale:            diffs.Stale,
+			})
+		}
+	}
+
+	return plainMessage("Unknown location " + args + ", known locations are " + strings.Join(sortedLocations(diffs), ", "))
+}
+
+func (service *service) commandWho(args string) (string, string) {
+	diffs := service.latestDiffs.Load()
+	if diffs == nil {
+		return noDataMessage()
+	}
+	if args == "" {
+		return plainMessage("Please name a troll, e.g. " + commandPrefix + "who nick")
+	}
+
+	lines := []string{}
+	for _, location := range sortedLocations(diffs) {
+		diff := diffs.DiffsInLocations[location]
+		for _, state := range []struct {
+			Verb  string
+			Users []shiftdiff.User
+		}{
+			{"arriving", diff.UsersArriving},
+			{"staying", diff.UsersWorking},
+			{"leaving", diff.UsersLeaving},
+		} {
+			for _, user := range state.Users {
+				if strings.EqualFold(user.Nickname, args) {
+					lines = append(lines, user.Nickname+" is "+state.Verb+" at "+location+" ("+user.ShiftName+shiftNameToEmoji(user.ShiftName)+")")
+				}
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return plainMessage(args + " is not arriving, staying or leaving around the next shift change")
+	}
+
+	return plainMessage(strings.Join(lines, "\n"))
+}
+
+func (service *service) commandOpen(_ string) (string, string) {
+	diffs := service.latestDiffs.Load()
+	if diffs == nil {
+		return noDataMessage()
+	}
+
+	lines := []string{}
+	for _, location := range sortedLocations(diffs) {
+		openUsers := diffs.DiffsInLocations[location].OpenUsers
+		angelTypes := make([]string, 0, len(openUsers))
+		for angelType := range openUsers {
+			angelTypes = append(angelTypes, angelType)
+		}
+		sort.Strings(angelTypes)
+
+		for _, angelType := range angelTypes {
+			lines = append(lines, "🚨 "+strconv.Itoa(int(openUsers[angelType]))+"x "+angelType+shiftNameToEmoji(angelType)+" open at "+location)
+		}
+	}
+
+	if len(lines) == 0 {
+		return plainMessage("✅ No open positions in the next shifts")
+	}
+
+	return plainMessage(strings.Join(lines, "\n"))
+}
+
+func (service *service) commandHelp(_ string) (string, string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{"Available commands:"}
+	for _, name := range names {
+		line := commandPrefix + name
+		if commands[name].Usage != "" {
+			line += " " + commands[name].Usage
+		}
+		lines = append(lines, line+": "+commands[name].Description)
+	}
+
+	return plainMessage(strings.Join(lines, "\n"))
+}
+
+func noDataMessage() (string, string) {
+	return plainMessage("No data yet, please try again after the next shift check.")
+}
+
+// plainMessage returns the message with an HTML escaped formatted version.
+func plainMessage(msg string) (string, string) {
+	return msg, strings.ReplaceAll(html.EscapeString(msg), "\n", "<br>\n")
+}
+
+func sortedLocations(diffs *shiftDiffs) []string {
+	locations := make([]string, 0, len(diffs.DiffsInLocations))
+	for location := range diffs.DiffsInLocations {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	return locations
+}
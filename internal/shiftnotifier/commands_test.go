@@ -0,0 +1,168 @@
+package shiftnotifier
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+)
+
+func TestHandleCommand(t *testing.T) {
+	tests := []struct {
+		name           string
+		message        matrixmessenger.IncomingMessage
+		wantAnswer     bool
+		wantThreadRoot string
+	}{
+		{
+			name:           "allowed room",
+			message:        matrixmessenger.IncomingMessage{EventID: "$cmd", ChannelExternalIdentifier: "!room:example.org", Body: "!help"},
+			wantAnswer:     true,
+			wantThreadRoot: "$cmd",
+		},
+		{
+			name:           "second allowed room",
+			message:        matrixmessenger.IncomingMessage{EventID: "$cmd", ChannelExternalIdentifier: "!orga:example.org", Body: "!HELP"},
+			wantAnswer:     true,
+			wantThreadRoot: "$cmd",
+		},
+		{
+			name:           "in thread",
+			message:        matrixmessenger.IncomingMessage{EventID: "$cmd", ChannelExternalIdentifier: "!room:example.org", Body: "!help", ThreadRootMessage: "$root"},
+			wantAnswer:     true,
+			wantThreadRoot: "$root",
+		},
+		{
+			name:    "room not allowed",
+			message: matrixmessenger.IncomingMessage{EventID: "$cmd", ChannelExternalIdentifier: "!other:example.org", Body: "!help"},
+		},
+		{
+			name:    "no command",
+			message: matrixmessenger.IncomingMessage{EventID: "$cmd", ChannelExternalIdentifier: "!room:example.org", Body: "help"},
+		},
+		{
+			name:    "unknown command",
+			message: matrixmessenger.IncomingMessage{EventID: "$cmd", ChannelExternalIdentifier: "!room:example.org", Body: "!shutdown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, messenger := newTestService(t, http.NotFoundHandler())
+			s.config.CommandRoomIDs = []string{"!room:example.org", "!orga:example.org"}
+			messages := recordMessages(messenger)
+
+			s.handleCommand(context.Background(), &tt.message)
+
+			if !tt.wantAnswer {
+				if len(*messages) != 0 {
+					t.Errorf("answered %q, want no answer", (*messages)[0].Body)
+				}
+				return
+			}
+			if len(*messages) != 1 {
+				t.Fatalf("sent %d answers, want 1", len(*messages))
+			}
+
+			answer := (*messages)[0]
+			if answer.ChannelExternalIdentifier != tt.message.ChannelExternalIdentifier {
+				t.Errorf("answered in %s, want %s", answer.ChannelExternalIdentifier, tt.message.ChannelExternalIdentifier)
+			}
+			if answer.ThreadRootMessage != tt.wantThreadRoot {
+				t.Errorf("answered in thread %q, want %q", answer.ThreadRootMessage, tt.wantThreadRoot)
+			}
+		})
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	s, _ := newTestService(t, http.NotFoundHandler())
+
+	tests := []struct {
+		name    string
+		handle  func(service *service, args string) (string, string)
+		args    string
+		want    string
+		noDiffs bool
+	}{
+		{
+			name:    "who without data",
+			handle:  (*service).commandWho,
+			args:    "Troll 3",
+			want:    "No data yet, please try again after the next shift check.",
+			noDiffs: true,
+		},
+		{
+			name:   "who without nick",
+			handle: (*service).commandWho,
+			want:   "Please name a troll, e.g. !who nick",
+		},
+		{
+			name:   "who arriving",
+			handle: (*service).commandWho,
+			args:   "troll 3",
+			want:   "Troll 3 is arriving at Bar Nord (Bar Nord Abend)",
+		},
+		{
+			name:   "who in multiple locations",
+			handle: (*service).commandWho,
+			args:   "Troll 1",
+			want:   "Troll 1 is leaving at Bar Nord (Bar Nord Früh)\nTroll 1 is arriving at Tschunk Bar (Tschunk Aufbau 🥃)",
+		},
+		{
+			name:   "who unknown",
+			handle: (*service).commandWho,
+			args:   "Troll 9",
+			want:   "Troll 9 is not arriving, staying or leaving around the next shift change",
+		},
+		{
+			name:   "open",
+			handle: (*service).commandOpen,
+			want:   "🚨 1x Bar-Theke 💶 open at Bar Nord\n🚨 2x Runner 🏃‍♀️ open at Bar Nord\n🚨 1x Tschunk 🥃 open at Tschunk Bar",
+		},
+		{
+			name:    "open without data",
+			handle:  (*service).commandOpen,
+			want:    "No data yet, please try again after the next shift check.",
+			noDiffs: true,
+		},
+	}
+
+	diffs := &shiftDiffs{
+		ReferenceTime: testRecordedAt,
+		DiffsInLocations: map[string]shiftdiff.Diff{
+			"Bar Nord": {
+				UsersArriving: []shiftdiff.User{{ID: 3, Nickname: "Troll 3", AngelType: "Bar-Theke", ShiftName: "Bar Nord Abend"}},
+				UsersWorking:  []shiftdiff.User{{ID: 2, Nickname: "Troll 2", AngelType: "Bar-Theke", ShiftName: "Bar Nord Abend"}},
+				UsersLeaving:  []shiftdiff.User{{ID: 1, Nickname: "Troll 1", AngelType: "Bar-Theke", ShiftName: "Bar Nord Früh"}},
+				OpenUsers:     map[string]int64{"Runner": 2, "Bar-Theke": 1},
+			},
+			"Tschunk Bar": {
+				UsersArriving: []shiftdiff.User{{ID: 1, Nickname: "Troll 1", AngelType: "Runner", ShiftName: "Tschunk Aufbau"}},
+				OpenUsers:     map[string]int64{"Tschunk": 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.latestDiffs = newDiffState()
+			if !tt.noDiffs {
+				s.latestDiffs.Store(diffs)
+			}
+
+			msg, _ := tt.handle(s, tt.args)
+			if msg != tt.want {
+				t.Errorf("answer = %q, want %q", msg, tt.want)
+			}
+		})
+	}
+
+	// Without open positions the command says so.
+	s.latestDiffs.Store(&shiftDiffs{DiffsInLocations: map[string]shiftdiff.Diff{"Bar Nord": {}}})
+	if msg, _ := s.commandOpen(""); msg != "✅ No open positions in the next shifts" {
+		t.Errorf("answer = %q, want no open positions", msg)
+	}
+}
@@ -0,0 +1,191 @@
+package shiftnotifier
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+)
+
+// escalationInterval defines how often upcoming shifts are checked for
+// understaffing.
+const escalationInterval = time.Minute * 2
+
+// checkUnderstaffing alerts the escalation room about upcoming shifts with
+// too many open positions and follows up once they are filled.
+func (service *service) checkUnderstaffing() {
+	if len(service.config.EscalationLeadTimes) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	locations, err := service.getLocations(ctx)
+	if err != nil {
+		slog.Error("failed to list locations", "error", err.Error())
+		return
+	}
+
+	now := time.Now()
+	shifts := service.listShiftsInLocations(ctx, locations, &angelapi.ListShiftsInLocationOpts{
+		From:  now,
+		Until: now.Add(slices.Max(service.config.EscalationLeadTimes)),
+	})
+
+	// Escalations of started shifts are not needed anymore.
+	err = service.store.DeleteEscalationsBefore(ctx, now)
+	if err != nil {
+		slog.Error("failed to delete past escalations", "error", err.Error())
+	}
+
+	for i, location := range locations {
+		for _, shift := range shifts[i] {
+			level, ok := service.escalationLevel(shift.StartsAt.Sub(now))
+			if !ok {
+				continue
+			}
+
+			openPositions := shiftdiff.OpenPositions(shift)
+			for _, angelType := range angelTypesInShift(shift) {
+				err := service.escalate(ctx, location, shift, angelType, openPositions[angelType], level)
+				if err != nil {
+					slog.Error("failed to escalate understaffing", "shift_id", shift.ID, "angel_type", angelType, "error", err.Error())
+				}
+			}
+		}
+	}
+}
+
+// escalate alerts about a single angel type in a shift if needed.
+func (service *service) escalate(ctx context.Context, location angelapi.Location, shift angelapi.Shift, angelType string, open int64, level time.Duration) error {
+	escalation, err := service.store.GetEscalation(ctx, shift.ID, angelType)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	isActive := escalation != nil && escalation.ResolvedAt.IsZero()
+
+	if open < service.escalationThreshold(location.Name) {
+		if !isActive {
+			return nil
+		}
+
+		// The gap was filled, tell everyone who got alerted.
+		escalation.ResolvedAt = time.Now()
+		escalation.Open = open
+		escalation.ShiftStartsAt = shift.StartsAt
+		msg := "✅ Filled: " + escalationSubject(location, shift, angelType, open)
+		_, err := service.sendEscalation(ctx, msg, msg, escalation.EventID)
+		if err != nil {
+			return err
+		}
+
+		return service.store.SaveEscalation(ctx, escalation)
+	}
+
+	// Only alert once per level, more urgent levels have smaller lead times.
+	if isActive && escalation.Level <= level {
+		return nil
+	}
+
+	if !isActive {
+		escalation = &store.Escalation{
+			ShiftID:    shift.ID,
+			AngelType:  angelType,
+			LocationID: location.ID,
+		}
+	}
+	// Shifts might be moved, keep the start up to date for pruning.
+	escalation.ShiftStartsAt = shift.StartsAt
+
+	msg := "🚨 Understaffed: " + escalationSubject(location, shift, angelType, open)
+	msgFormatted := msg
+	if len(service.config.EscalationMentions) > 0 {
+		mentions := make([]string, 0, len(service.config.EscalationMentions))
+		for _, userID := range service.config.EscalationMentions {
+			mentions = append(mentions, matrixmessenger.GetMatrixLinkForUser(userID))
+		}
+
+		msg += "\n" + strings.Join(service.config.EscalationMentions, " ")
+		msgFormatted += "<br>\n" + strings.Join(mentions, " ")
+	}
+
+	eventID, err := service.sendEscalation(ctx, msg, msgFormatted, escalation.EventID)
+	if err != nil {
+		return err
+	}
+
+	if escalation.EventID == "" {
+		escalation.EventID = eventID
+	}
+	escalation.Level = level
+	escalation.Open = open
+	escalation.AlertedAt = time.Now()
+
+	return service.store.SaveEscalation(ctx, escalation)
+}
+
+// sendEscalation sends a message to the escalation room, optionally as reply
+// to a previous alert.
+func (service *service) sendEscalation(ctx context.Context, msg, msgFormatted, replyTo string) (string, error) {
+	message := matrixmessenger.HTMLMessage(msg, msgFormatted, service.config.EscalationRoomID)
+	message.ResponseToMessage = replyTo
+
+	resp, err := service.messenger.SendMessage(ctx, message)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.ExternalIdentifier, nil
+}
+
+// escalationLevel returns the most urgent lead time reached.
+func (service *service) escalationLevel(timeUntilShiftStart time.Duration) (time.Duration, bool) {
+	if timeUntilShiftStart <= 0 {
+		return 0, false
+	}
+
+	level := time.Duration(0)
+	for _, leadTime := range service.config.EscalationLeadTimes {
+		if timeUntilShiftStart <= leadTime && (level == 0 || leadTime < level) {
+			level = leadTime
+		}
+	}
+
+	return level, level > 0
+}
+
+func (service *service) escalationThreshold(locationName string) int64 {
+	if threshold, ok := service.config.EscalationThresholds[locationName]; ok {
+		return threshold
+	}
+
+	return service.config.EscalationDefaultThreshold
+}
+
+func escalationSubject(location angelapi.Location, shift angelapi.Shift, angelType string, open int64) string {
+	return strconv.Itoa(int(open)) + "x " + angelType + shiftNameToEmoji(angelType) +
+		" open in " + shift.Title +
+		" at " + location.Name +
+		" (" + shift.StartsAt.In(defaultTimeZone()).Format("Mon, 15:04") +
+		", starts in " + strings.TrimSuffix(time.Until(shift.StartsAt).Round(time.Minute).String(), "0s") + ")"
+}
+
+func angelTypesInShift(shift angelapi.Shift) []string {
+	angelTypes := []string{}
+	for _, entry := range shift.Entries {
+		if !slices.Contains(angelTypes, entry.Type.Name) {
+			angelTypes = append(angelTypes, entry.Type.Name)
+		}
+	}
+
+	return angelTypes
+}
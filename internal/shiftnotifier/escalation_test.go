@@ -0,0 +1,175 @@
+package shiftnotifier
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/Cubicroots-Playground/trollinfo/internal/fakeengelsystem"
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+	"github.com/golang/mock/gomock"
+)
+
+// recordMessages records all messages sent and answers with increasing event
+// IDs.
+func recordMessages(messenger *matrixmessenger.MockMessenger) *[]*matrixmessenger.Message {
+	messages := []*matrixmessenger.Message{}
+	messenger.EXPECT().SendMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, message *matrixmessenger.Message) (*matrixmessenger.MessageResponse, error) {
+			messages = append(messages, message)
+			return &matrixmessenger.MessageResponse{ExternalIdentifier: "$event-" + strconv.Itoa(len(messages))}, nil
+		},
+	).AnyTimes()
+
+	return &messages
+}
+
+func newEscalationTestService(t *testing.T, handler http.Handler) (*service, *[]*matrixmessenger.Message) {
+	t.Helper()
+
+	s, messenger := newTestService(t, handler)
+	s.config.EscalationRoomID = "!escalation:example.org"
+	s.config.EscalationLeadTimes = []time.Duration{time.Hour * 2, time.Hour, time.Minute * 15}
+	s.config.EscalationDefaultThreshold = 1
+	s.config.EscalationThresholds = map[string]int64{"Tschunk Bar": 2}
+
+	return s, recordMessages(messenger)
+}
+
+func TestEscalationLevel(t *testing.T) {
+	s, _ := newEscalationTestService(t, http.NotFoundHandler())
+
+	tests := []struct {
+		untilStart time.Duration
+		wantLevel  time.Duration
+	}{
+		{untilStart: time.Hour * 3},
+		{untilStart: time.Hour*2 + time.Second},
+		{untilStart: time.Hour * 2, wantLevel: time.Hour * 2},
+		{untilStart: time.Minute * 90, wantLevel: time.Hour * 2},
+		{untilStart: time.Hour, wantLevel: time.Hour},
+		{untilStart: time.Minute * 30, wantLevel: time.Hour},
+		{untilStart: time.Minute * 10, wantLevel: time.Minute * 15},
+		{untilStart: 0},
+		{untilStart: -time.Minute * 5},
+	}
+
+	for _, tt := range tests {
+		level, ok := s.escalationLevel(tt.untilStart)
+		if level != tt.wantLevel || ok != (tt.wantLevel > 0) {
+			t.Errorf("escalationLevel(%v) = %v, %t, want %v", tt.untilStart, level, ok, tt.wantLevel)
+		}
+	}
+
+	if threshold := s.escalationThreshold("Tschunk Bar"); threshold != 2 {
+		t.Errorf("escalationThreshold(Tschunk Bar) = %d, want 2", threshold)
+	}
+	if threshold := s.escalationThreshold("Bar Nord"); threshold != 1 {
+		t.Errorf("escalationThreshold(Bar Nord) = %d, want the default 1", threshold)
+	}
+}
+
+func TestEscalate(t *testing.T) {
+	s, messages := newEscalationTestService(t, http.NotFoundHandler())
+	ctx := context.Background()
+	location := angelapi.Location{ID: 1, Name: "Bar Nord"}
+	shift := angelapi.Shift{ID: 103, Title: "Bar Nord Nacht", StartsAt: time.Now().Add(time.Hour * 2)}
+
+	steps := []struct {
+		name        string
+		open        int64
+		level       time.Duration
+		wantMessage string
+		wantReplyTo string
+	}{
+		{name: "first alert", open: 2, level: time.Hour * 2, wantMessage: "🚨 Understaffed: 2x Runner"},
+		{name: "same level", open: 2, level: time.Hour * 2},
+		{name: "less open on same level", open: 1, level: time.Hour * 2},
+		{name: "more urgent level", open: 1, level: time.Hour, wantMessage: "🚨 Understaffed: 1x Runner", wantReplyTo: "$event-1"},
+		{name: "same level again", open: 1, level: time.Hour},
+		{name: "resolved", open: 0, level: time.Minute * 15, wantMessage: "✅ Filled: 0x Runner", wantReplyTo: "$event-1"},
+		{name: "still resolved", open: 0, level: time.Minute * 15},
+		{name: "open again", open: 1, level: time.Minute * 15, wantMessage: "🚨 Understaffed: 1x Runner"},
+	}
+
+	for _, step := range steps {
+		sent := len(*messages)
+		err := s.escalate(ctx, location, shift, "Runner", step.open, step.level)
+		if err != nil {
+			t.Fatalf("%s: escalate() error = %v", step.name, err)
+		}
+
+		if step.wantMessage == "" {
+			if len(*messages) != sent {
+				t.Errorf("%s: sent %q, want no message", step.name, (*messages)[len(*messages)-1].Body)
+			}
+			continue
+		}
+		if len(*messages) != sent+1 {
+			t.Fatalf("%s: sent %d messages, want 1", step.name, len(*messages)-sent)
+		}
+
+		message := (*messages)[sent]
+		if !strings.HasPrefix(message.Body, step.wantMessage) {
+			t.Errorf("%s: sent %q, want %q", step.name, message.Body, step.wantMessage)
+		}
+		if message.ResponseToMessage != step.wantReplyTo {
+			t.Errorf("%s: reply to %q, want %q", step.name, message.ResponseToMessage, step.wantReplyTo)
+		}
+		if message.ChannelExternalIdentifier != "!escalation:example.org" {
+			t.Errorf("%s: sent to %s, want the escalation room", step.name, message.ChannelExternalIdentifier)
+		}
+	}
+
+	escalation, err := s.store.GetEscalation(ctx, shift.ID, "Runner")
+	if err != nil {
+		t.Fatalf("GetEscalation() error = %v", err)
+	}
+	if escalation.EventID != "$event-4" || !escalation.ResolvedAt.IsZero() || !escalation.ShiftStartsAt.Equal(shift.StartsAt) {
+		t.Errorf("escalation = %+v, want the new alert for the shift", escalation)
+	}
+}
+
+func TestCheckUnderstaffing(t *testing.T) {
+	engelsystem := fakeengelsystem.New(&fakeengelsystem.Config{
+		Clock: time.Now,
+	})
+	s, messages := newEscalationTestService(t, engelsystem)
+	s.config.EscalationLeadTimes = []time.Duration{time.Hour * 3}
+	ctx := context.Background()
+
+	past := &store.Escalation{ShiftID: 1, AngelType: "Runner", ShiftStartsAt: time.Now().Add(-time.Minute)}
+	err := s.store.SaveEscalation(ctx, past)
+	if err != nil {
+		t.Fatalf("SaveEscalation() error = %v", err)
+	}
+
+	// Upcoming are Bar Nord Nacht with one open Bar-Theke and Runner
+	// position each and Tschunk Nacht with one open position, below the
+	// threshold of the Tschunk Bar.
+	s.checkUnderstaffing()
+	if len(*messages) != 2 {
+		t.Fatalf("sent %d alerts, want 2", len(*messages))
+	}
+	for _, message := range *messages {
+		if !strings.Contains(message.Body, "Bar Nord Nacht") {
+			t.Errorf("unexpected alert %q", message.Body)
+		}
+	}
+
+	// Alerts are deduplicated across runs.
+	s.checkUnderstaffing()
+	if len(*messages) != 2 {
+		t.Errorf("sent %d alerts after second run, want 2", len(*messages))
+	}
+
+	_, err = s.store.GetEscalation(ctx, past.ShiftID, past.AngelType)
+	if err != store.ErrNotFound {
+		t.Errorf("escalation of past shift error = %v, want %v", err, store.ErrNotFound)
+	}
+}
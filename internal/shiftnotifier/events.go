@@ -0,0 +1,92 @@
+package shiftnotifier
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// eventsKeepAlive defines how often a comment is sent to idle event streams
+// so proxies do not close them.
+const eventsKeepAlive = time.Second * 30
+
+// serveEvents streams the diffs as server-sent events whenever they change.
+func (service *service) serveEvents(w http.ResponseWriter, r *http.Request) {
+	_, err := service.requireToken(r, ScopeDisplayOnly)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("unauthorized"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("streaming not supported"))
+		return
+	}
+
+	// Streams stay open, they are closed on shutdown instead.
+	err = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		slog.Warn("failed to lift write deadline for event stream", "error", err.Error())
+	}
+
+	updates, unsubscribe := service.latestDiffs.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if diffs := service.latestDiffs.Load(); diffs != nil {
+		if !writeDiffsEvent(w, diffs) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-service.closing:
+			return
+		case diffs := <-updates:
+			if !writeDiffsEvent(w, diffs) {
+				return
+			}
+		case <-keepAlive.C:
+			_, err := w.Write([]byte(": keep-alive\n\n"))
+			if err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeDiffsEvent writes the diffs in the API format as event, it returns
+// false if the stream is broken.
+func writeDiffsEvent(w http.ResponseWriter, diffs *shiftDiffs) bool {
+	data, err := json.Marshal(&apiResponse{
+		Meta: apiMeta{
+			ReferenceTime: diffs.ReferenceTime,
+			GeneratedAt:   time.Now(),
+			Stale:         diffs.Stale,
+		},
+		Data: toAPIDiff(diffs),
+	})
+	if err != nil {
+		slog.Error("failed marshaling data", "error", err.Error())
+		return true
+	}
+
+	_, err = w.Write([]byte("event: diffs\ndata: " + string(data) + "\n\n"))
+	return err == nil
+}
@@ -5,20 +5,17 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
 )
 
 func (service *service) diffToMessage(diffs *shiftDiffs) (string, string) {
 	msg := strings.Builder{}
 	msgHTML := strings.Builder{}
 
-	defaultTZ, err := time.LoadLocation("Europe/Berlin")
-	if err != nil {
-		defaultTZ = time.Local
-	}
-
 	timeStr := diffs.ReferenceTime.
 		Add(service.config.NotifyBeforeShiftStart).
-		In(defaultTZ).
+		In(defaultTimeZone()).
 		Format("Mon, 15:04")
 
 	// Title.
@@ -95,7 +92,17 @@ func (service *service) diffToMessage(diffs *shiftDiffs) (string, string) {
 	return msg.String(), msgHTML.String()
 }
 
-func usersToList(users []shiftUser, msg *strings.Builder, msgHTML *strings.Builder) {
+// defaultTimeZone returns the time zone times are displayed in.
+func defaultTimeZone() *time.Location {
+	defaultTZ, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		return time.Local
+	}
+
+	return defaultTZ
+}
+
+func usersToList(users []shiftdiff.User, msg *strings.Builder, msgHTML *strings.Builder) {
 	if len(users) == 0 {
 		msg.WriteString("  _none_\n")
 		msgHTML.WriteString("&nbsp;&nbsp;<i>none</i><br>\n")
@@ -108,14 +115,22 @@ func usersToList(users []shiftUser, msg *strings.Builder, msgHTML *strings.Build
 		msg.WriteString(" (")
 		msg.WriteString(user.ShiftName)
 		msg.WriteString(shiftNameToEmoji(user.ShiftName))
-		msg.WriteString(")\n")
+		msg.WriteString(")")
+		if user.CheckedIn {
+			msg.WriteString(" ✅")
+		}
+		msg.WriteString("\n")
 
 		msgHTML.WriteString("&nbsp;&nbsp;- ")
 		msgHTML.WriteString(user.Nickname)
 		msgHTML.WriteString(" <i>(")
 		msgHTML.WriteString(user.ShiftName)
 		msgHTML.WriteString(shiftNameToEmoji(user.ShiftName))
-		msgHTML.WriteString(")</i><br>\n")
+		msgHTML.WriteString(")</i>")
+		if user.CheckedIn {
+			msgHTML.WriteString(" ✅")
+		}
+		msgHTML.WriteString("<br>\n")
 	}
 }
 
@@ -0,0 +1,47 @@
+package shiftnotifier
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// refreshCurrentDiffs recomputes the diffs for the next shift start, so the
+// web views are up to date outside the notification window as well. Messages
+// already sent for the shift start are edited if the diffs changed.
+func (service *service) refreshCurrentDiffs() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	shiftStarts, _, err := service.getUpcomingShiftStarts(ctx, now)
+	if err != nil {
+		slog.Error("failed to refresh diffs", "error", err.Error())
+		return
+	}
+
+	// Without upcoming shifts show who is working right now.
+	refTime := now
+	nextShiftStart := time.Time{}
+	for shiftStart := range shiftStarts {
+		if nextShiftStart.IsZero() || shiftStart.Before(nextShiftStart) {
+			nextShiftStart = shiftStart
+		}
+	}
+	if !nextShiftStart.IsZero() {
+		refTime = nextShiftStart.Add(-service.config.NotifyBeforeShiftStart)
+	}
+
+	diffs, err := service.computeDiffs(ctx, refTime)
+	if err != nil {
+		slog.Error("failed to refresh diffs", "error", err.Error())
+		return
+	}
+
+	service.latestDiffs.Store(diffs)
+
+	err = service.editSentMessages(ctx, diffs)
+	if err != nil {
+		slog.Error("failed to update sent messages", "error", err.Error())
+	}
+}
@@ -0,0 +1,113 @@
+package shiftnotifier
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+)
+
+// seedUserMappings stores the configured user mappings, keeping the direct
+// rooms of already known users.
+func (service *service) seedUserMappings(ctx context.Context) {
+	for _, mapping := range service.config.DirectReminderUsers {
+		existing, err := service.store.GetUserMapping(ctx, mapping.EngelsystemUserID, mapping.Nickname)
+		if err == nil && existing.MatrixUserID == mapping.MatrixUserID {
+			continue
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			slog.Error("failed to load user mapping", "matrix_user_id", mapping.MatrixUserID, "error", err.Error())
+			continue
+		}
+
+		err = service.store.SaveUserMapping(ctx, &mapping)
+		if err != nil {
+			slog.Error("failed to store user mapping", "matrix_user_id", mapping.MatrixUserID, "error", err.Error())
+		}
+	}
+}
+
+// sendDirectReminders sends a direct message to every arriving troll with a
+// known matrix user.
+func (service *service) sendDirectReminders(ctx context.Context, diffs *shiftDiffs) {
+	if !service.config.DirectRemindersEnabled {
+		return
+	}
+
+	shiftStart := diffs.ReferenceTime.Add(service.config.NotifyBeforeShiftStart)
+	for location, diff := range diffs.DiffsInLocations {
+		for _, user := range diff.UsersArriving {
+			err := service.sendDirectReminder(ctx, location, &diff, user, shiftStart)
+			if err != nil {
+				slog.Error("failed to send direct reminder", "user_id", user.ID, "error", err.Error())
+			}
+		}
+	}
+}
+
+func (service *service) sendDirectReminder(ctx context.Context, location string, diff *shiftdiff.Diff, user shiftdiff.User, shiftStart time.Time) error {
+	mapping, err := service.store.GetUserMapping(ctx, user.ID, user.Nickname)
+	if errors.Is(err, store.ErrNotFound) {
+		// Users have to opt in by being mapped.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if mapping.LastReminderFor.Equal(shiftStart) {
+		return nil
+	}
+
+	if mapping.DirectRoomID == "" {
+		channel, err := service.messenger.CreateChannel(ctx, mapping.MatrixUserID)
+		if err != nil {
+			return err
+		}
+
+		mapping.DirectRoomID = channel.ChannelExternalIdentifier
+		err = service.store.SaveUserMapping(ctx, mapping)
+		if err != nil {
+			return err
+		}
+	}
+
+	msg := strings.Builder{}
+	msg.WriteString("👋 Hi ")
+	msg.WriteString(user.Nickname)
+	msg.WriteString(", your shift ")
+	msg.WriteString(user.ShiftName)
+	msg.WriteString(" as ")
+	msg.WriteString(user.AngelType)
+	msg.WriteString(shiftNameToEmoji(user.AngelType))
+	msg.WriteString(" at 📍 ")
+	msg.WriteString(location)
+	msg.WriteString(" starts at ")
+	msg.WriteString(shiftStart.In(defaultTimeZone()).Format("Mon, 15:04"))
+	msg.WriteString(".\n")
+
+	relieving := []string{}
+	for _, leavingUser := range diff.UsersLeaving {
+		if leavingUser.AngelType == user.AngelType {
+			relieving = append(relieving, leavingUser.Nickname)
+		}
+	}
+	if len(relieving) > 0 {
+		msg.WriteString("You are relieving ")
+		msg.WriteString(strings.Join(relieving, ", "))
+		msg.WriteString(".\n")
+	}
+
+	_, err = service.messenger.SendMessage(ctx, matrixmessenger.PlainTextMessage(msg.String(), mapping.DirectRoomID))
+	if err != nil {
+		return err
+	}
+
+	mapping.LastReminderFor = shiftStart
+	return service.store.SaveUserMapping(ctx, mapping)
+}
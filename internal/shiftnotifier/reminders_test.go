@@ -0,0 +1,115 @@
+package shiftnotifier
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+	"github.com/golang/mock/gomock"
+)
+
+func testReminderDiffs(refTime time.Time) *shiftDiffs {
+	return &shiftDiffs{
+		ReferenceTime: refTime,
+		DiffsInLocations: map[string]shiftdiff.Diff{"Bar Nord": {
+			UsersArriving: []shiftdiff.User{
+				{ID: 3, Nickname: "Troll 3", AngelType: "Bar-Theke", ShiftName: "Bar Nord Abend"},
+				{ID: 4, Nickname: "Troll 4", AngelType: "Runner", ShiftName: "Bar Nord Abend"},
+				{ID: 5, Nickname: "Troll 5", AngelType: "Runner", ShiftName: "Bar Nord Abend"},
+			},
+			UsersLeaving: []shiftdiff.User{
+				{ID: 1, Nickname: "Troll 1", AngelType: "Bar-Theke", ShiftName: "Bar Nord Früh"},
+			},
+		}},
+	}
+}
+
+func TestSendDirectReminders(t *testing.T) {
+	s, messenger := newTestService(t, http.NotFoundHandler())
+	s.config.DirectRemindersEnabled = true
+	s.config.DirectReminderUsers = []store.UserMapping{
+		// Mapped by ID without a direct room yet.
+		{EngelsystemUserID: 3, MatrixUserID: "@troll3:example.org"},
+		// Mapped by nickname, the direct room is known already.
+		{Nickname: "troll 4", MatrixUserID: "@troll4:example.org", DirectRoomID: "!dm4:example.org"},
+	}
+	ctx := context.Background()
+	s.seedUserMappings(ctx)
+
+	messenger.EXPECT().CreateChannel(gomock.Any(), "@troll3:example.org").
+		Return(&matrixmessenger.ChannelResponse{ChannelExternalIdentifier: "!dm3:example.org"}, nil).
+		Times(1)
+	sent := map[string][]string{}
+	messenger.EXPECT().SendMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, message *matrixmessenger.Message) (*matrixmessenger.MessageResponse, error) {
+			sent[message.ChannelExternalIdentifier] = append(sent[message.ChannelExternalIdentifier], message.Body)
+			return &matrixmessenger.MessageResponse{ExternalIdentifier: "$event"}, nil
+		},
+	).AnyTimes()
+
+	refTime := testRecordedAt.Add(-time.Minute * 15)
+	s.sendDirectReminders(ctx, testReminderDiffs(refTime))
+
+	if len(sent) != 2 || len(sent["!dm3:example.org"]) != 1 || len(sent["!dm4:example.org"]) != 1 {
+		t.Fatalf("sent %v, want one reminder to each mapped user", sent)
+	}
+	if body := sent["!dm3:example.org"][0]; !strings.Contains(body, "Hi Troll 3") || !strings.Contains(body, "relieving Troll 1") {
+		t.Errorf("reminder for Troll 3 = %q, want greeting and relieved troll", body)
+	}
+	if body := sent["!dm4:example.org"][0]; !strings.Contains(body, "Hi Troll 4") || strings.Contains(body, "relieving") {
+		t.Errorf("reminder for Troll 4 = %q, want greeting without relieved trolls", body)
+	}
+
+	// Reminders are only sent once per shift.
+	s.sendDirectReminders(ctx, testReminderDiffs(refTime))
+	if len(sent["!dm3:example.org"]) != 1 || len(sent["!dm4:example.org"]) != 1 {
+		t.Errorf("sent %v, want no further reminders for the same shift", sent)
+	}
+
+	// The next shift reuses the created direct room.
+	s.sendDirectReminders(ctx, testReminderDiffs(refTime.Add(time.Hour*2)))
+	if len(sent["!dm3:example.org"]) != 2 || len(sent["!dm4:example.org"]) != 2 {
+		t.Errorf("sent %v, want reminders for the next shift", sent)
+	}
+
+	mapping, err := s.store.GetUserMapping(ctx, 3, "Troll 3")
+	if err != nil {
+		t.Fatalf("GetUserMapping() error = %v", err)
+	}
+	if mapping.DirectRoomID != "!dm3:example.org" || !mapping.LastReminderFor.Equal(refTime.Add(time.Hour*2+time.Minute*15)) {
+		t.Errorf("mapping = %+v, want the direct room and last shift start", mapping)
+	}
+}
+
+func TestSeedUserMappingsKeepsDirectRooms(t *testing.T) {
+	s, _ := newTestService(t, http.NotFoundHandler())
+	ctx := context.Background()
+	err := s.store.SaveUserMapping(ctx, &store.UserMapping{
+		EngelsystemUserID: 3,
+		MatrixUserID:      "@troll3:example.org",
+		DirectRoomID:      "!dm3:example.org",
+	})
+	if err != nil {
+		t.Fatalf("SaveUserMapping() error = %v", err)
+	}
+
+	s.config.DirectReminderUsers = []store.UserMapping{
+		{EngelsystemUserID: 3, MatrixUserID: "@troll3:example.org"},
+		{EngelsystemUserID: 4, MatrixUserID: "@troll4:example.org"},
+	}
+	s.seedUserMappings(ctx)
+
+	mapping, err := s.store.GetUserMapping(ctx, 3, "")
+	if err != nil || mapping.DirectRoomID != "!dm3:example.org" {
+		t.Errorf("mapping of known user = %+v (%v), want the direct room kept", mapping, err)
+	}
+	mapping, err = s.store.GetUserMapping(ctx, 4, "")
+	if err != nil || mapping.MatrixUserID != "@troll4:example.org" {
+		t.Errorf("mapping of new user = %+v (%v), want it stored", mapping, err)
+	}
+}
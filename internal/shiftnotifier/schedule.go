@@ -0,0 +1,109 @@
+package shiftnotifier
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/go-co-op/gocron/v2"
+)
+
+const (
+	// planInterval defines how often upcoming shifts are checked for
+	// changed start times.
+	planInterval = time.Minute * 10
+	// planAhead defines how far upcoming shifts are looked at.
+	planAhead = time.Hour * 3
+)
+
+// planNotifications schedules a notification for each distinct start time of
+// upcoming shifts and removes notifications for start times that vanished.
+func (service *service) planNotifications() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	shiftStarts, complete, err := service.getUpcomingShiftStarts(ctx, now)
+	if err != nil {
+		slog.Error("failed to plan notifications", "error", err.Error())
+		return
+	}
+	if !complete {
+		// Start times of failed locations would look vanished.
+		slog.Warn("shifts of some locations missing, keeping planned notifications")
+	}
+
+	for shiftStart := range service.plannedShiftStarts {
+		if shiftStart.Before(now) {
+			// Past notifications are done, forget about them.
+			delete(service.plannedShiftStarts, shiftStart)
+			continue
+		}
+
+		if complete && !shiftStarts[shiftStart] && shiftStart.Add(-service.config.NotifyBeforeShiftStart).After(now) {
+			slog.Info("shift start vanished, removing notification", "shift_start", shiftStart)
+			service.scheduler.RemoveByTags(notificationTag(shiftStart))
+			delete(service.plannedShiftStarts, shiftStart)
+		}
+	}
+
+	for shiftStart := range shiftStarts {
+		if service.plannedShiftStarts[shiftStart] {
+			continue
+		}
+
+		// Notifications we missed, e.g. due to a restart, are sent right away.
+		startAt := gocron.OneTimeJobStartImmediately()
+		if notifyAt := shiftStart.Add(-service.config.NotifyBeforeShiftStart); notifyAt.After(now) {
+			startAt = gocron.OneTimeJobStartDateTime(notifyAt)
+		}
+
+		_, err := service.scheduler.NewJob(
+			gocron.OneTimeJob(startAt),
+			gocron.NewTask(service.notifyShifts, shiftStart),
+			gocron.WithTags(notificationTag(shiftStart)),
+		)
+		if err != nil {
+			slog.Error("failed to schedule notification", "shift_start", shiftStart, "error", err.Error())
+			continue
+		}
+
+		slog.Info("scheduled notification", "shift_start", shiftStart)
+		service.plannedShiftStarts[shiftStart] = true
+	}
+}
+
+// getUpcomingShiftStarts returns the distinct start times of shifts starting
+// within planAhead in all configured locations and whether the shifts of all
+// locations were fetched.
+func (service *service) getUpcomingShiftStarts(ctx context.Context, now time.Time) (map[time.Time]bool, bool, error) {
+	locations, err := service.getLocations(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	shifts := service.listShiftsInLocations(ctx, locations, &angelapi.ListShiftsInLocationOpts{
+		From:  now,
+		Until: now.Add(planAhead),
+	})
+
+	complete := true
+	shiftStarts := map[time.Time]bool{}
+	for _, shiftsInLocation := range shifts {
+		if shiftsInLocation == nil {
+			complete = false
+		}
+		for _, shift := range shiftsInLocation {
+			if shift.StartsAt.After(now) {
+				shiftStarts[shift.StartsAt.UTC()] = true
+			}
+		}
+	}
+
+	return shiftStarts, complete, nil
+}
+
+func notificationTag(shiftStart time.Time) string {
+	return "notify-" + shiftStart.UTC().Format(time.RFC3339)
+}
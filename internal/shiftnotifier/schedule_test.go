@@ -0,0 +1,81 @@
+package shiftnotifier
+
+import (
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/fakeengelsystem"
+	"github.com/go-co-op/gocron/v2"
+)
+
+func TestPlanNotifications(t *testing.T) {
+	engelsystem := fakeengelsystem.New(&fakeengelsystem.Config{
+		Clock: time.Now,
+	})
+	failTschunkBar := atomic.Bool{}
+	s, _ := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failTschunkBar.Load() && r.URL.Path == "/api/v0-beta/locations/2/shifts" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		engelsystem.ServeHTTP(w, r)
+	}))
+
+	scheduler, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatalf("gocron.NewScheduler() error = %v", err)
+	}
+	t.Cleanup(func() { _ = scheduler.Shutdown() })
+	s.scheduler = scheduler
+
+	// The fixtures are moved to the current hour, only the Tschunk Bar has a
+	// shift starting at half past.
+	hour := time.Now().Truncate(time.Hour).UTC()
+	onlyTschunkBar := hour.Add(time.Minute * 90)
+	both := hour.Add(time.Hour * 2)
+	vanished := hour.Add(time.Minute * 150)
+
+	_, err = scheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(vanished)),
+		gocron.NewTask(func() {}),
+		gocron.WithTags(notificationTag(vanished)),
+	)
+	if err != nil {
+		t.Fatalf("NewJob() error = %v", err)
+	}
+	s.plannedShiftStarts[vanished] = true
+
+	assertPlanned := func(want ...time.Time) {
+		t.Helper()
+
+		tags := []string{}
+		for _, job := range scheduler.Jobs() {
+			tags = append(tags, job.Tags()...)
+		}
+		for _, shiftStart := range want {
+			if !s.plannedShiftStarts[shiftStart] || !slices.Contains(tags, notificationTag(shiftStart)) {
+				t.Errorf("notification for %v not planned, jobs %v", shiftStart, tags)
+			}
+		}
+		if len(s.plannedShiftStarts) != len(want) || len(tags) != len(want) {
+			t.Errorf("planned %v with jobs %v, want %v", s.plannedShiftStarts, tags, want)
+		}
+	}
+
+	// A failed location keeps the planned notifications.
+	failTschunkBar.Store(true)
+	s.planNotifications()
+	assertPlanned(vanished, both)
+
+	failTschunkBar.Store(false)
+	s.planNotifications()
+	assertPlanned(onlyTschunkBar, both)
+
+	// Start times only the failed location has are not removed.
+	failTschunkBar.Store(true)
+	s.planNotifications()
+	assertPlanned(onlyTschunkBar, both)
+}
@@ -0,0 +1,75 @@
+package shiftnotifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	httpReadHeaderTimeout = time.Second * 10
+	httpReadTimeout       = time.Second * 30
+	// httpWriteTimeout must exceed the API timeout, event streams lift it.
+	httpWriteTimeout    = time.Minute
+	httpIdleTimeout     = time.Minute * 2
+	httpShutdownTimeout = time.Second * 10
+)
+
+// newServer assembles the HTTP server with all handlers.
+func (service *service) newServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data", service.serveJSONData)
+	mux.HandleFunc("/", service.serveHumanPortrait)
+	mux.HandleFunc("/landscape", service.serveHumanLandscape)
+	mux.HandleFunc("/events", service.serveEvents)
+	mux.HandleFunc("POST /auth/links", service.serveCreateAuthLink)
+	mux.HandleFunc("GET /auth/exchange", service.serveExchangeAuthLink)
+	service.registerAPI(mux)
+
+	addr := service.config.ListenAddr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		WriteTimeout:      httpWriteTimeout,
+		IdleTimeout:       httpIdleTimeout,
+	}
+}
+
+// listen binds the listen address so failures surface before starting the
+// jobs. Errors while serving are sent to the returned channel.
+func (service *service) listen() (<-chan error, error) {
+	listener, err := net.Listen("tcp", service.server.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", service.server.Addr, err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		err := service.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("failed serving HTTP: %w", err)
+		}
+	}()
+
+	return serveErr, nil
+}
+
+// shutdownServer closes open event streams and waits for other requests to
+// finish.
+func (service *service) shutdownServer() error {
+	close(service.closing)
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+
+	return service.server.Shutdown(ctx)
+}
@@ -2,28 +2,55 @@ package shiftnotifier
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
 	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/Cubicroots-Playground/trollinfo/internal/sink"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
 	"github.com/go-co-op/gocron/v2"
+	"golang.org/x/sync/errgroup"
 
 	_ "time/tzdata"
 )
 
+const (
+	// notifyTimeout limits how long the notifier keeps retrying.
+	notifyTimeout    = time.Minute * 4
+	notifyRetryDelay = time.Second * 10
+)
+
 type service struct {
 	angelAPI  angelapi.Service
 	messenger matrixmessenger.Messenger
+	sinks     []sink.Sink
+	store     store.Store
 	config    *Config
 	scheduler gocron.Scheduler
-	wg        *sync.WaitGroup
-
-	latestDiffs *shiftDiffs
+	server    *http.Server
+
+	// closing is closed when stopping, stopped once stopping finished.
+	closing  chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// plannedShiftStarts holds the shift start times notifications are
+	// scheduled for, only accessed by the planning job.
+	plannedShiftStarts map[time.Time]bool
+	changes            *changeTracker
+	checkIns           *checkInTracker
+	sinkDeliveries     *sinkDeliveries
+
+	latestDiffs *diffState
+	authLinks   *authLinks
 }
 
 // Config holds the configuration for the shift notifier.
@@ -31,43 +58,206 @@ type Config struct {
 	LocationNames          []string
 	NotifyBeforeShiftStart time.Duration
 	MatrixRoomID           string
+	// MaxConcurrentRequests limits how many locations are fetched at once.
+	MaxConcurrentRequests int
+	// ChangePollInterval defines how often shifts are checked for changes,
+	// change detection is disabled if zero.
+	ChangePollInterval time.Duration
+	// ChangeDebounce defines how long a change has to persist before it is
+	// announced.
+	ChangeDebounce time.Duration
+	// RefreshInterval defines how often the diffs shown in the web views are
+	// recomputed, they are only computed for notifications if zero.
+	RefreshInterval time.Duration
+
+	// EscalationRoomID is the matrix room understaffing alerts are sent
+	// to, alerts are disabled if empty.
+	EscalationRoomID string
+	// EscalationLeadTimes define when before a shift starts alerts are
+	// sent, every lead time is a level alerted once.
+	EscalationLeadTimes []time.Duration
+	// EscalationThresholds hold the amount of open positions per angel type
+	// alerting per location name.
+	EscalationThresholds       map[string]int64
+	EscalationDefaultThreshold int64
+	// EscalationMentions hold matrix user IDs mentioned in alerts.
+	EscalationMentions []string
+
+	// DirectRemindersEnabled enables direct messages to arriving trolls
+	// with a known matrix user.
+	DirectRemindersEnabled bool
+	// DirectReminderUsers hold the configured user mappings.
+	DirectReminderUsers []store.UserMapping
+
+	// CheckInEnabled lets arriving trolls acknowledge shift messages with a
+	// reaction.
+	CheckInEnabled bool
+	// CheckInLeads hold matrix user IDs mentioned about trolls not checked in
+	// at shift start.
+	CheckInLeads []string
+
+	// CommandRoomIDs hold the matrix rooms the bot answers commands in.
+	CommandRoomIDs []string
 
 	ListenAddr string
-	Token      string
+	// Tokens hold the tokens allowed to access the HTTP server.
+	Tokens []HTTPToken
+	// SessionDuration defines how long cookies set by one-time links are
+	// valid.
+	SessionDuration time.Duration
 }
 
 // ParseFromEnvironment parses the config from the environment.
 func (c *Config) ParseFromEnvironment() {
 	c.LocationNames = strings.Split(os.Getenv("TROLLINFO_LOCATIONS"), ",")
 	c.MatrixRoomID = os.Getenv("TROLLINFO_MATRIX_ROOM_ID")
-	c.NotifyBeforeShiftStart = time.Minute * 15
+	c.NotifyBeforeShiftStart = durationFromEnvironment("TROLLINFO_NOTIFY_BEFORE_SHIFT_START", time.Minute*15)
+	c.MaxConcurrentRequests = 4
+	if maxConcurrentRequests := os.Getenv("TROLLINFO_MAX_CONCURRENT_REQUESTS"); maxConcurrentRequests != "" {
+		parsed, err := strconv.Atoi(maxConcurrentRequests)
+		if err != nil || parsed < 1 {
+			slog.Warn("invalid max concurrent requests, using default", "value", maxConcurrentRequests)
+		} else {
+			c.MaxConcurrentRequests = parsed
+		}
+	}
+	c.ChangePollInterval = durationFromEnvironment("TROLLINFO_CHANGE_POLL_INTERVAL", time.Minute*3)
+	c.ChangeDebounce = durationFromEnvironment("TROLLINFO_CHANGE_DEBOUNCE", time.Minute*5)
+	c.RefreshInterval = durationFromEnvironment("TROLLINFO_REFRESH_INTERVAL", time.Minute*5)
+	c.EscalationRoomID = os.Getenv("TROLLINFO_ESCALATION_ROOM_ID")
+	c.EscalationLeadTimes = []time.Duration{time.Hour * 2, time.Hour, time.Minute * 15}
+	if leadTimes := os.Getenv("TROLLINFO_ESCALATION_LEAD_TIMES"); leadTimes != "" {
+		c.EscalationLeadTimes = []time.Duration{}
+		for _, leadTime := range strings.Split(leadTimes, ",") {
+			parsed, err := time.ParseDuration(strings.TrimSpace(leadTime))
+			if err != nil || parsed <= 0 {
+				slog.Warn("invalid escalation lead time, ignoring it", "value", leadTime)
+				continue
+			}
+			c.EscalationLeadTimes = append(c.EscalationLeadTimes, parsed)
+		}
+	}
+	c.EscalationDefaultThreshold = 1
+	if threshold := os.Getenv("TROLLINFO_ESCALATION_THRESHOLD"); threshold != "" {
+		parsed, err := strconv.ParseInt(threshold, 10, 64)
+		if err != nil || parsed < 1 {
+			slog.Warn("invalid escalation threshold, using default", "value", threshold)
+		} else {
+			c.EscalationDefaultThreshold = parsed
+		}
+	}
+	c.EscalationThresholds = map[string]int64{}
+	if thresholds := os.Getenv("TROLLINFO_ESCALATION_THRESHOLDS"); thresholds != "" {
+		for _, threshold := range strings.Split(thresholds, ",") {
+			location, value, _ := strings.Cut(threshold, "=")
+			parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil || parsed < 1 {
+				slog.Warn("invalid escalation threshold, ignoring it", "value", threshold)
+				continue
+			}
+			c.EscalationThresholds[strings.TrimSpace(location)] = parsed
+		}
+	}
+	if mentions := os.Getenv("TROLLINFO_ESCALATION_MENTIONS"); mentions != "" {
+		c.EscalationMentions = strings.Split(mentions, ",")
+	}
+	c.DirectRemindersEnabled = os.Getenv("TROLLINFO_DIRECT_REMINDERS") == "true"
+	if users := os.Getenv("TROLLINFO_DIRECT_REMINDER_USERS"); users != "" {
+		for _, user := range strings.Split(users, ",") {
+			engelsystemUser, matrixUserID, ok := strings.Cut(user, "=")
+			if !ok {
+				slog.Warn("invalid direct reminder user, ignoring it", "value", user)
+				continue
+			}
+
+			mapping := store.UserMapping{
+				MatrixUserID: strings.TrimSpace(matrixUserID),
+			}
+			if userID, err := strconv.ParseInt(strings.TrimSpace(engelsystemUser), 10, 64); err == nil {
+				mapping.EngelsystemUserID = userID
+			} else {
+				mapping.Nickname = strings.TrimSpace(engelsystemUser)
+			}
+			c.DirectReminderUsers = append(c.DirectReminderUsers, mapping)
+		}
+	}
+	c.CheckInEnabled = os.Getenv("TROLLINFO_CHECK_IN") == "true"
+	if leads := os.Getenv("TROLLINFO_CHECK_IN_LEADS"); leads != "" {
+		c.CheckInLeads = strings.Split(leads, ",")
+	}
+	if rooms := os.Getenv("TROLLINFO_COMMAND_ROOMS"); rooms != "" {
+		c.CommandRoomIDs = strings.Split(rooms, ",")
+	} else if c.MatrixRoomID != "" {
+		c.CommandRoomIDs = []string{c.MatrixRoomID}
+	}
 	c.ListenAddr = os.Getenv("TROLLINFO_HTTP_LISTEN_ADDR")
-	c.Token = os.Getenv("TROLLINFO_HTTP_TOKEN")
+	if token := os.Getenv("TROLLINFO_HTTP_TOKEN"); token != "" {
+		c.Tokens = append(c.Tokens, HTTPToken{Name: "default", Scope: ScopeFullData, Token: token})
+	}
+	if tokens := os.Getenv("TROLLINFO_HTTP_TOKENS"); tokens != "" {
+		for _, token := range strings.Split(tokens, ",") {
+			parts := strings.SplitN(strings.TrimSpace(token), ":", 3)
+			if len(parts) != 3 || parts[0] == "" || strings.Contains(parts[0], ".") || !TokenScope(parts[1]).valid() || parts[2] == "" {
+				slog.Warn("invalid HTTP token, ignoring it", "name", parts[0])
+				continue
+			}
+			c.Tokens = append(c.Tokens, HTTPToken{Name: parts[0], Scope: TokenScope(parts[1]), Token: parts[2]})
+		}
+	}
+	c.SessionDuration = durationFromEnvironment("TROLLINFO_HTTP_SESSION_DURATION", time.Hour*24*30)
+}
+
+func durationFromEnvironment(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		slog.Warn("invalid duration, using default", "name", name, "value", value)
+		return fallback
+	}
+
+	return duration
 }
 
-// New assembles a new shift notifier.
-func New(config *Config, angelAPI angelapi.Service, messenger matrixmessenger.Messenger) Service {
+// New assembles a new shift notifier. Shift notifications are sent to the
+// matrix room and fanned out to the sinks.
+func New(config *Config, angelAPI angelapi.Service, messenger matrixmessenger.Messenger, sinks []sink.Sink, store store.Store) Service {
 	s := &service{
 		angelAPI:  angelAPI,
 		messenger: messenger,
+		sinks:     sinks,
+		store:     store,
 		config:    config,
-		wg:        &sync.WaitGroup{},
+		closing:   make(chan struct{}),
+		stopped:   make(chan struct{}),
+
+		plannedShiftStarts: make(map[time.Time]bool),
+		changes:            newChangeTracker(),
+		checkIns:           newCheckInTracker(),
+		sinkDeliveries:     newSinkDeliveries(),
+		latestDiffs:        newDiffState(),
+		authLinks:          newAuthLinks(),
 	}
 
-	http.HandleFunc("/data", s.serveJSONData)
-	http.HandleFunc("/", s.serveHumanPortrait)
-	http.HandleFunc("/landscape", s.serveHumanLandscape)
+	messenger.OnMessage(s.handleCommand)
+	messenger.OnReaction(s.handleCheckIn)
+
+	s.server = s.newServer()
 
 	return s
 }
 
 func (service *service) Start() error {
-	go func(config Config) {
-		err := http.ListenAndServe(config.ListenAddr, nil)
-		if err != nil {
-			slog.Error("failed serving HTTP server", "error", err)
-		}
-	}(*service.config)
+	serveErr, err := service.listen()
+	if err != nil {
+		return err
+	}
+
+	service.restoreLatestDiffs()
+	service.seedUserMappings(context.Background())
 
 	s, err := gocron.NewScheduler()
 	if err != nil {
@@ -77,265 +267,293 @@ func (service *service) Start() error {
 	service.scheduler = s
 
 	_, err = service.scheduler.NewJob(
-		// Always 14 Minutes before full hour.
-		gocron.CronJob("46 * * * *", false),
-		gocron.NewTask(service.notifyShifts),
+		gocron.DurationJob(planInterval),
+		gocron.NewTask(service.planNotifications),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
 	)
 	if err != nil {
 		return err
 	}
 
-	// If we are between XX:46 and XX:59 get the diffs now!
-	if time.Now().Minute() > 46 {
-		_ = service.getNextShifts()
+	if service.config.RefreshInterval > 0 {
+		_, err = service.scheduler.NewJob(
+			gocron.DurationJob(service.config.RefreshInterval),
+			gocron.NewTask(service.refreshCurrentDiffs),
+			gocron.WithStartAt(gocron.WithStartImmediately()),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if service.config.ChangePollInterval > 0 {
+		_, err = service.scheduler.NewJob(
+			gocron.DurationJob(service.config.ChangePollInterval),
+			gocron.NewTask(service.detectChanges),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if service.config.EscalationRoomID != "" {
+		_, err = service.scheduler.NewJob(
+			gocron.DurationJob(escalationInterval),
+			gocron.NewTask(service.checkUnderstaffing),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Start the scheduler.
-	service.wg.Add(1)
 	service.scheduler.Start()
 	slog.Info("started notifier", "jobs", len(service.scheduler.Jobs()))
 
-	service.wg.Wait()
-	return nil
+	select {
+	case err := <-serveErr:
+		return err
+	case <-service.stopped:
+		return nil
+	}
 }
 
 func (service *service) Stop() error {
-	err := service.scheduler.Shutdown()
-	service.wg.Done()
-
-	return err
-}
+	var err error
+	service.stopOnce.Do(func() {
+		defer close(service.stopped)
 
-type shiftUser struct {
-	Nickname  string
-	AngelType string
-	ShiftName string
-}
+		err = service.shutdownServer()
+		if service.scheduler != nil {
+			err = errors.Join(err, service.scheduler.Shutdown())
+		}
+	})
 
-type shiftDiff struct {
-	UsersLeaving  []shiftUser
-	UsersWorking  []shiftUser
-	UsersArriving []shiftUser
-	ExpectedUsers int64
-	OpenUsers     map[string]int64
+	return err
 }
 
 type shiftDiffs struct {
-	DiffsInLocations map[string]shiftDiff
+	DiffsInLocations map[string]shiftdiff.Diff
 	ReferenceTime    time.Time
+	// ShiftStart is the start of the shifts the trolls arrive for.
+	ShiftStart time.Time
+	// Stale is set if the Engelsystem was down and cached data was used.
+	Stale bool
 }
 
-func (service *service) notifyShifts() {
-	deadline := time.Now().Add(time.Minute * 4)
-	var err error
-	for time.Until(deadline) > 0 {
-		err = service.getNextShifts()
-		if err == nil {
-			break
-		}
-	}
-	if err != nil {
-		slog.Error("failed to run notifier", "error", err.Error())
-	}
-}
-
-func (service *service) getNextShifts() error {
-	slog.Info("checking shifts now")
+func (service *service) notifyShifts(shiftStart time.Time) {
+	refTime := shiftStart.Add(-service.config.NotifyBeforeShiftStart)
 
-	locations, err := service.getLocationIDs()
-	if err != nil {
-		slog.Error("failed to list locations", "error", err.Error())
-		return err
+	// Retry with increasing delays until the shift starts getting close.
+	deadline := time.Now().Add(notifyTimeout)
+	if shiftStart.Before(deadline) {
+		deadline = shiftStart
 	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
 
-	diffs := map[string]shiftDiff{}
-
-	// Use time.Date(2024, 5, 31, 19, 54, 0, 0, time.UTC) for testing.
-	refTime := time.Now()
-
-	for locationID, locationName := range locations {
-		diff := shiftDiff{
-			UsersLeaving:  []shiftUser{},
-			UsersWorking:  []shiftUser{},
-			UsersArriving: []shiftUser{},
-			OpenUsers:     map[string]int64{},
+	delay := notifyRetryDelay
+	for {
+		err := service.getNextShifts(ctx, refTime)
+		if err == nil {
+			return
 		}
 
-		shifts, err := service.angelAPI.ListShiftsInLocation(locationID, nil)
-		if err != nil {
-			slog.Error("failed to list shifts", "location_id", locationID, "error", err.Error())
-			continue
+		wait, retry := service.handleAPIError(ctx, err)
+		if !retry {
+			return
 		}
-
-		for _, shift := range shifts {
-			timeUntilShiftStart := shift.StartsAt.Sub(refTime)
-			timeUntilShiftEnd := shift.EndsAt.Sub(refTime)
-
-			// Next shift, users should arrive.
-			if timeUntilShiftStart > 0 && timeUntilShiftStart < time.Minute*15 {
-				for _, shiftEntry := range shift.Entries {
-					diff.ExpectedUsers += shiftEntry.Needs
-
-					for _, user := range shiftEntry.Users {
-						diff.UsersArriving = append(diff.UsersArriving, shiftUser{
-							Nickname:  user.NickName,
-							AngelType: shiftEntry.Type.Name,
-							ShiftName: shift.Title,
-						})
-					}
-
-					diff.OpenUsers[shiftEntry.Type.Name] += shiftEntry.Needs - int64(len(diff.UsersArriving))
-				}
-				continue
-			}
-
-			// Previous shift, users should leave.
-			if timeUntilShiftStart < 0 &&
-				timeUntilShiftEnd > 0 &&
-				timeUntilShiftEnd <= (service.config.NotifyBeforeShiftStart+time.Minute) {
-				for _, shiftEntry := range shift.Entries {
-					for _, user := range shiftEntry.Users {
-						diff.UsersLeaving = append(diff.UsersArriving, shiftUser{
-							Nickname:  user.NickName,
-							AngelType: shiftEntry.Type.Name,
-							ShiftName: shift.Title,
-						})
-					}
-				}
-				continue
-			}
-
-			// Overlapping shift, users should stay.
-			if timeUntilShiftStart < 0 && timeUntilShiftEnd > 0 {
-				for _, shiftEntry := range shift.Entries {
-					for _, user := range shiftEntry.Users {
-						diff.UsersWorking = append(diff.UsersArriving, shiftUser{
-							Nickname:  user.NickName,
-							AngelType: shiftEntry.Type.Name,
-							ShiftName: shift.Title,
-						})
-					}
-				}
-				continue
-			}
+		if wait < delay {
+			wait = delay
 		}
 
-		diffs[locationName] = diff
-	}
-
-	service.latestDiffs = &shiftDiffs{
-		DiffsInLocations: service.cleanUpDiffs(diffs),
-		ReferenceTime:    refTime,
-	}
-
-	// Only send message if we have any content.
-	hasContent := false
-	for _, diff := range service.latestDiffs.DiffsInLocations {
-		if len(diff.UsersArriving) > 0 || len(diff.UsersLeaving) > 0 {
-			hasContent = true
-			break
+		select {
+		case <-ctx.Done():
+			slog.Error("failed to run notifier", "error", err.Error())
+			if errors.Is(err, angelapi.ErrServerUnavailable) {
+				service.alertRoom("⚠️ The Engelsystem is unavailable, no troll changes this time.")
+			}
+			return
+		case <-time.After(wait):
+			slog.Warn("failed to run notifier, retrying", "error", err.Error())
 		}
+		delay *= 2
 	}
+}
 
-	if !hasContent {
-		return nil
+// handleAPIError decides how to proceed after a failed run. It returns
+// whether to retry and the minimum delay to wait before doing so.
+func (service *service) handleAPIError(ctx context.Context, err error) (time.Duration, bool) {
+	var apiErr *angelapi.APIError
+	switch {
+	case errors.Is(err, angelapi.ErrUnauthorized):
+		slog.Error("Engelsystem rejected the API key, giving up", "error", err.Error())
+		service.alertRoom("⚠️ The Engelsystem rejected my API key, please check the configuration.")
+		return 0, false
+	case errors.Is(err, angelapi.ErrNotFound):
+		slog.Error("Engelsystem resource not found, giving up", "error", err.Error())
+		return 0, false
+	case errors.Is(err, angelapi.ErrRateLimited) && errors.As(err, &apiErr):
+		return apiErr.RetryAfter, ctx.Err() == nil
 	}
 
-	msg, msgFormatted := service.diffToMessage(service.latestDiffs)
+	return 0, ctx.Err() == nil
+}
 
+// alertRoom sends a short notice about operational problems to the room.
+func (service *service) alertRoom(msg string) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
-	_, err = service.messenger.SendMessage(ctx, matrixmessenger.HTMLMessage(
-		msg, msgFormatted, service.config.MatrixRoomID,
-	))
+	_, err := service.messenger.SendMessage(ctx, matrixmessenger.PlainTextMessage(msg, service.config.MatrixRoomID))
+	if err != nil {
+		slog.Error("failed to send alert to matrix room", "error", err.Error())
+	}
+}
+
+func (service *service) getNextShifts(ctx context.Context, refTime time.Time) error {
+	slog.Info("checking shifts now")
+
+	diffs, err := service.computeDiffs(ctx, refTime)
 	if err != nil {
-		slog.Error("failed to send matrix message", "error", err.Error())
 		return err
 	}
 
+	service.latestDiffs.Store(diffs)
+	err = service.store.SaveDiffSnapshot(ctx, &store.DiffSnapshot{
+		DiffsInLocations: diffs.DiffsInLocations,
+		ReferenceTime:    refTime,
+		ComputedAt:       time.Now(),
+		Stale:            diffs.Stale,
+	})
+	if err != nil {
+		slog.Error("failed to store diffs", "error", err.Error())
+	}
+
+	sinkErr := service.notifySinks(ctx, diffs)
+	service.sendDirectReminders(ctx, diffs)
+	if sinkErr != nil {
+		service.retrySinks(ctx, diffs, sinkErr)
+	}
+
 	return nil
 }
 
-func (service *service) getLocationIDs() (map[int64]string, error) {
-	locations, err := service.angelAPI.ListLocations(nil)
+// errNoShiftsFetched is returned if the shifts of no location could be
+// fetched, the run is retried.
+var errNoShiftsFetched = errors.New("failed to list shifts of all locations")
+
+// computeDiffs fetches the shifts around refTime and computes the diffs of
+// all locations.
+func (service *service) computeDiffs(ctx context.Context, refTime time.Time) (*shiftDiffs, error) {
+	freshness := &angelapi.Freshness{}
+	ctx = angelapi.WithFreshness(ctx, freshness)
+
+	locations, err := service.getLocations(ctx)
 	if err != nil {
+		slog.Error("failed to list locations", "error", err.Error())
 		return nil, err
 	}
 
-	locationMap := make(map[int64]string)
+	shifts := service.listShiftsInLocations(ctx, locations, &angelapi.ListShiftsInLocationOpts{
+		From:  refTime,
+		Until: refTime.Add(service.config.NotifyBeforeShiftStart + time.Minute),
+	})
 
-	for _, location := range locations {
-		isKnown := false
-		for _, loc := range service.config.LocationNames {
-			if loc == location.Name {
-				isKnown = true
-				break
-			}
-		}
-
-		if !isKnown {
-			continue
+	// Failed locations are skipped, the others are still announced.
+	diffs := map[string]shiftdiff.Diff{}
+	for i, location := range locations {
+		if shifts[i] != nil {
+			diffs[location.Name] = shiftdiff.Compute(shifts[i], refTime, service.config.NotifyBeforeShiftStart)
 		}
-
-		locationMap[location.ID] = location.Name
+	}
+	if len(diffs) == 0 && len(locations) > 0 {
+		return nil, errNoShiftsFetched
 	}
 
-	return locationMap, nil
+	return service.checkIns.apply(&shiftDiffs{
+		DiffsInLocations: diffs,
+		ReferenceTime:    refTime,
+		ShiftStart:       refTime.Add(service.config.NotifyBeforeShiftStart),
+		Stale:            freshness.Stale(),
+	}), nil
 }
 
-func (service *service) cleanUpDiffs(diffs map[string]shiftDiff) map[string]shiftDiff {
-	// Ugly af, needs refactoring. Users that are leaving & arriving should be moved to the
-	// "working" list.
-	newDiffs := make(map[string]shiftDiff)
-
-	skipLeavingUser := []shiftUser{}
-	for location, diff := range diffs {
-		newDiff := shiftDiff{
-			UsersLeaving:  []shiftUser{},
-			UsersWorking:  []shiftUser{},
-			UsersArriving: []shiftUser{},
-			OpenUsers:     diffs[location].OpenUsers,
-			ExpectedUsers: diffs[location].ExpectedUsers,
+// restoreLatestDiffs loads the diffs computed before a restart.
+func (service *service) restoreLatestDiffs() {
+	snapshot, err := service.store.LatestDiffSnapshot(context.Background())
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.Error("failed to restore diffs", "error", err.Error())
 		}
+		return
+	}
+
+	service.latestDiffs.Store(&shiftDiffs{
+		DiffsInLocations: snapshot.DiffsInLocations,
+		ReferenceTime:    snapshot.ReferenceTime,
+		ShiftStart:       snapshot.ReferenceTime.Add(service.config.NotifyBeforeShiftStart),
+		Stale:            snapshot.Stale,
+	})
+}
 
-		for _, user := range diff.UsersArriving {
-			isUserStaying := false
-			for i := range diff.UsersLeaving {
-				if user.Nickname == diff.UsersLeaving[i].Nickname {
-					isUserStaying = true
-					skipLeavingUser = append(skipLeavingUser, diff.UsersLeaving[i])
-					newDiff.UsersWorking = append(newDiff.UsersWorking, user)
-					break
-				}
+// listShiftsInLocations fetches the shifts of all locations concurrently.
+// The result is in location order, failed locations are logged and nil.
+func (service *service) listShiftsInLocations(ctx context.Context, locations []angelapi.Location, opts *angelapi.ListShiftsInLocationOpts) [][]angelapi.Shift {
+	results := make([][]angelapi.Shift, len(locations))
+	eg := errgroup.Group{}
+	eg.SetLimit(service.config.MaxConcurrentRequests)
+	for i, location := range locations {
+		eg.Go(func() error {
+			shifts, err := service.angelAPI.ListShiftsInLocation(ctx, location.ID, opts)
+			if err != nil {
+				slog.Error("failed to list shifts", "location_id", location.ID, "error", err.Error())
+				return nil
 			}
-			if isUserStaying {
-				continue
+			if shifts == nil {
+				shifts = []angelapi.Shift{}
 			}
 
-			newDiff.UsersArriving = append(newDiff.UsersArriving, user)
-		}
+			results[i] = shifts
 
-		for _, user := range diff.UsersLeaving {
-			isUserStaying := false
-			for _, skipUser := range skipLeavingUser {
-				if user.Nickname == skipUser.Nickname {
-					isUserStaying = true
-					break
-				}
+			err = service.store.SaveShiftSnapshot(ctx, &store.ShiftSnapshot{
+				LocationID:   location.ID,
+				LocationName: location.Name,
+				FetchedAt:    time.Now(),
+				Shifts:       shifts,
+			})
+			if err != nil {
+				slog.Error("failed to store shifts", "location_id", location.ID, "error", err.Error())
 			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
 
-			if isUserStaying {
-				continue
-			}
+	return results
+}
 
-			newDiff.UsersLeaving = append(newDiff.UsersLeaving, user)
-		}
+// getLocations returns the configured locations in the configured order.
+func (service *service) getLocations(ctx context.Context) ([]angelapi.Location, error) {
+	locations, err := service.angelAPI.ListLocations(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
 
-		newDiff.UsersWorking = append(newDiff.UsersWorking, diff.UsersWorking...)
+	knownLocations := make([]angelapi.Location, 0, len(service.config.LocationNames))
 
-		newDiffs[location] = newDiff
+	for _, loc := range service.config.LocationNames {
+		for _, location := range locations {
+			if loc == location.Name {
+				knownLocations = append(knownLocations, location)
+				break
+			}
+		}
 	}
 
-	return newDiffs
+	return knownLocations, nil
 }
@@ -0,0 +1,398 @@
+package shiftnotifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/Cubicroots-Playground/trollinfo/internal/fakeengelsystem"
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/Cubicroots-Playground/trollinfo/internal/sink"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+	"github.com/golang/mock/gomock"
+)
+
+// testRecordedAt is the recording time of the default fixtures.
+var testRecordedAt = time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC)
+
+// newTestService assembles a notifier fetching from the handler, usually a
+// fake Engelsystem.
+func newTestService(t *testing.T, handler http.Handler) (*service, *matrixmessenger.MockMessenger) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	angelAPI := angelapi.New(&angelapi.Config{
+		BaseURL:        server.URL,
+		TimeZone:       time.UTC,
+		Timeout:        time.Second * 5,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  time.Millisecond,
+	})
+
+	messenger := matrixmessenger.NewMockMessenger(gomock.NewController(t))
+	messenger.EXPECT().OnMessage(gomock.Any()).AnyTimes()
+	messenger.EXPECT().OnReaction(gomock.Any()).AnyTimes()
+
+	sinks, err := sink.New(&sink.Config{Sinks: []sink.SinkConfig{{
+		Name:   sink.MainName,
+		Type:   sink.TypeMatrix,
+		RoomID: "!room:example.org",
+	}}}, messenger)
+	if err != nil {
+		t.Fatalf("sink.New() error = %v", err)
+	}
+
+	s := New(&Config{
+		LocationNames:          []string{"Bar Nord", "Tschunk Bar"},
+		NotifyBeforeShiftStart: time.Minute * 15,
+		MatrixRoomID:           "!room:example.org",
+		MaxConcurrentRequests:  2,
+	}, angelAPI, messenger, sinks, store.NewMemory(0))
+
+	return s.(*service), messenger
+}
+
+func newTestEngelsystem() *fakeengelsystem.Server {
+	return fakeengelsystem.New(&fakeengelsystem.Config{
+		Clock: func() time.Time { return testRecordedAt },
+	})
+}
+
+func TestGetNextShiftsSkipsFailedLocation(t *testing.T) {
+	engelsystem := newTestEngelsystem()
+	s, messenger := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v0-beta/locations/2/shifts" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		engelsystem.ServeHTTP(w, r)
+	}))
+	ctx := context.Background()
+	refTime := testRecordedAt.Add(-time.Minute * 15)
+
+	messenger.EXPECT().SendMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, message *matrixmessenger.Message) (*matrixmessenger.MessageResponse, error) {
+			if !strings.Contains(message.Body, "Bar Nord") || !strings.Contains(message.Body, "Troll 3") {
+				t.Errorf("message does not announce Bar Nord:\n%s", message.Body)
+			}
+			return &matrixmessenger.MessageResponse{ExternalIdentifier: "$event"}, nil
+		},
+	)
+
+	err := s.getNextShifts(ctx, refTime)
+	if err != nil {
+		t.Fatalf("getNextShifts() error = %v", err)
+	}
+
+	diffs := s.latestDiffs.Load()
+	if _, ok := diffs.DiffsInLocations["Bar Nord"]; !ok {
+		t.Error("diffs miss the fetched location")
+	}
+	if _, ok := diffs.DiffsInLocations["Tschunk Bar"]; ok {
+		t.Error("diffs contain the failed location")
+	}
+}
+
+func TestGetNextShiftsFailsWithoutLocations(t *testing.T) {
+	s, _ := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/shifts") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		newTestEngelsystem().ServeHTTP(w, r)
+	}))
+
+	previous := &shiftDiffs{ReferenceTime: testRecordedAt.Add(-time.Hour)}
+	s.latestDiffs.Store(previous)
+
+	err := s.getNextShifts(context.Background(), testRecordedAt.Add(-time.Minute*15))
+	if !errors.Is(err, errNoShiftsFetched) {
+		t.Fatalf("getNextShifts() error = %v, want %v", err, errNoShiftsFetched)
+	}
+	if _, retry := s.handleAPIError(context.Background(), err); !retry {
+		t.Error("run should be retried")
+	}
+
+	if diffs := s.latestDiffs.Load(); diffs != previous {
+		t.Errorf("latest diffs were replaced with %+v", diffs)
+	}
+	if _, err := s.store.LatestDiffSnapshot(context.Background()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("diffs of a failed fetch were persisted, error = %v", err)
+	}
+}
+
+func TestComputeDiffs(t *testing.T) {
+	s, _ := newTestService(t, newTestEngelsystem())
+
+	refTime := testRecordedAt.Add(-time.Minute * 15)
+	diffs, err := s.computeDiffs(context.Background(), refTime)
+	if err != nil {
+		t.Fatalf("computeDiffs() error = %v", err)
+	}
+
+	user := func(id int64, nickname, angelType, shiftName string) shiftdiff.User {
+		return shiftdiff.User{ID: id, Nickname: nickname, AngelType: angelType, ShiftName: shiftName}
+	}
+	want := map[string]shiftdiff.Diff{
+		"Bar Nord": {
+			UsersLeaving: []shiftdiff.User{user(1, "Troll 1", "Bar-Theke", "Bar Nord Früh")},
+			UsersWorking: []shiftdiff.User{user(2, "Troll 2", "Bar-Theke", "Bar Nord Abend")},
+			UsersArriving: []shiftdiff.User{
+				user(3, "Troll 3", "Bar-Theke", "Bar Nord Abend"),
+				user(4, "Troll 4", "Runner", "Bar Nord Abend"),
+			},
+			ExpectedUsers: 4,
+			OpenUsers:     map[string]int64{"Bar-Theke": 1},
+		},
+		"Tschunk Bar": {
+			UsersLeaving: []shiftdiff.User{},
+			UsersWorking: []shiftdiff.User{
+				user(6, "Troll 6", "Tschunk", "Tschunk Spät"),
+				user(7, "Troll 7", "Tschunk", "Tschunk Spät"),
+			},
+			UsersArriving: []shiftdiff.User{},
+			OpenUsers:     map[string]int64{},
+		},
+	}
+
+	if !reflect.DeepEqual(diffs.DiffsInLocations, want) {
+		t.Errorf("computeDiffs() =\n%+v\nwant\n%+v", diffs.DiffsInLocations, want)
+	}
+	if !diffs.ReferenceTime.Equal(refTime) || !diffs.ShiftStart.Equal(testRecordedAt) {
+		t.Errorf("computeDiffs() reference time %v and shift start %v, want %v and %v", diffs.ReferenceTime, diffs.ShiftStart, refTime, testRecordedAt)
+	}
+}
+
+func TestGetNextShifts(t *testing.T) {
+	s, messenger := newTestService(t, newTestEngelsystem())
+	ctx := context.Background()
+	refTime := testRecordedAt.Add(-time.Minute * 15)
+
+	messenger.EXPECT().SendMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, message *matrixmessenger.Message) (*matrixmessenger.MessageResponse, error) {
+			for _, nickname := range []string{"Troll 1", "Troll 3", "Troll 4"} {
+				if !strings.Contains(message.Body, nickname) {
+					t.Errorf("message does not mention %s:\n%s", nickname, message.Body)
+				}
+			}
+			return &matrixmessenger.MessageResponse{ExternalIdentifier: "$event", Timestamp: time.Now()}, nil
+		},
+	)
+
+	err := s.getNextShifts(ctx, refTime)
+	if err != nil {
+		t.Fatalf("getNextShifts() error = %v", err)
+	}
+
+	if diffs := s.latestDiffs.Load(); diffs == nil || !diffs.ReferenceTime.Equal(refTime) {
+		t.Errorf("latest diffs = %+v, want diffs for %v", diffs, refTime)
+	}
+	notification, err := s.store.LatestNotification(ctx, sink.MainName, refTime)
+	if err != nil || notification.EventID != "$event" {
+		t.Errorf("LatestNotification() = %+v, %v, want event $event", notification, err)
+	}
+
+	// Unchanged diffs neither send nor edit the message.
+	err = s.getNextShifts(ctx, refTime)
+	if err != nil {
+		t.Fatalf("getNextShifts() error = %v", err)
+	}
+}
+
+func TestNotifySinksRoutesLocations(t *testing.T) {
+	s, messenger := newTestService(t, newTestEngelsystem())
+	ctx := context.Background()
+
+	payloads := make(chan map[string]any, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]any{}
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			t.Errorf("invalid webhook payload: %v", err)
+		}
+		payloads <- payload
+	}))
+	t.Cleanup(webhook.Close)
+
+	sinks, err := sink.New(&sink.Config{Sinks: []sink.SinkConfig{
+		{Name: sink.MainName, Type: sink.TypeMatrix, RoomID: "!room:example.org", Locations: []string{"Tschunk Bar"}},
+		{Name: "bar-nord", Type: sink.TypeWebhook, URL: webhook.URL, Locations: []string{"Bar Nord"}},
+	}}, messenger)
+	if err != nil {
+		t.Fatalf("sink.New() error = %v", err)
+	}
+	s.sinks = sinks
+
+	diffs, err := s.computeDiffs(ctx, testRecordedAt.Add(-time.Minute*15))
+	if err != nil {
+		t.Fatalf("computeDiffs() error = %v", err)
+	}
+
+	// Nobody arrives or leaves at the Tschunk Bar, the main room stays quiet.
+	err = s.notifySinks(ctx, diffs)
+	if err != nil {
+		t.Fatalf("notifySinks() error = %v", err)
+	}
+
+	payload := <-payloads
+	raw, _ := json.Marshal(toAPIDiff(&shiftDiffs{
+		DiffsInLocations: map[string]shiftdiff.Diff{"Bar Nord": diffs.DiffsInLocations["Bar Nord"]},
+		ShiftStart:       diffs.ShiftStart,
+	}))
+	var want any
+	_ = json.Unmarshal(raw, &want)
+	if !reflect.DeepEqual(payload["data"], want) {
+		t.Errorf("webhook data = %v, want %v", payload["data"], want)
+	}
+}
+
+func TestEditSentMessages(t *testing.T) {
+	s, messenger := newTestService(t, newTestEngelsystem())
+	ctx := context.Background()
+
+	diffs, err := s.computeDiffs(ctx, testRecordedAt.Add(-time.Minute*15))
+	if err != nil {
+		t.Fatalf("computeDiffs() error = %v", err)
+	}
+
+	// Nothing was sent for the reference time yet.
+	err = s.editSentMessages(ctx, diffs)
+	if err != nil {
+		t.Fatalf("editSentMessages() error = %v", err)
+	}
+
+	messenger.EXPECT().SendMessage(gomock.Any(), gomock.Any()).
+		Return(&matrixmessenger.MessageResponse{ExternalIdentifier: "$event"}, nil)
+	err = s.notifySinks(ctx, diffs)
+	if err != nil {
+		t.Fatalf("notifySinks() error = %v", err)
+	}
+
+	// Troll 4 left the shift after the message was sent.
+	changed := *diffs
+	changed.DiffsInLocations = maps.Clone(diffs.DiffsInLocations)
+	barNord := changed.DiffsInLocations["Bar Nord"]
+	barNord.UsersArriving = barNord.UsersArriving[:1]
+	changed.DiffsInLocations["Bar Nord"] = barNord
+
+	messenger.EXPECT().EditMessage(gomock.Any(), "$event", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, message *matrixmessenger.Message) (*matrixmessenger.MessageResponse, error) {
+			if strings.Contains(message.Body, "Troll 4") {
+				t.Errorf("edited message still mentions Troll 4:\n%s", message.Body)
+			}
+			return &matrixmessenger.MessageResponse{ExternalIdentifier: "$edit"}, nil
+		},
+	)
+	err = s.editSentMessages(ctx, &changed)
+	if err != nil {
+		t.Fatalf("editSentMessages() error = %v", err)
+	}
+
+	// The edited message is up to date.
+	err = s.editSentMessages(ctx, &changed)
+	if err != nil {
+		t.Fatalf("editSentMessages() error = %v", err)
+	}
+}
+
+func TestGetNextShiftsWithFailingSink(t *testing.T) {
+	s, messenger := newTestService(t, newTestEngelsystem())
+	s.config.DirectRemindersEnabled = true
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	webhookCalls := atomic.Int64{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		webhookCalls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(webhook.Close)
+
+	sinks, err := sink.New(&sink.Config{Sinks: []sink.SinkConfig{
+		{Name: sink.MainName, Type: sink.TypeMatrix, RoomID: "!room:example.org"},
+		{Name: "broken", Type: sink.TypeWebhook, URL: webhook.URL},
+	}}, messenger)
+	if err != nil {
+		t.Fatalf("sink.New() error = %v", err)
+	}
+	s.sinks = sinks
+
+	err = s.store.SaveUserMapping(ctx, &store.UserMapping{EngelsystemUserID: 3, MatrixUserID: "@troll3:example.org", DirectRoomID: "!dm:example.org"})
+	if err != nil {
+		t.Fatalf("SaveUserMapping() error = %v", err)
+	}
+
+	messenger.EXPECT().SendMessage(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, message *matrixmessenger.Message) (*matrixmessenger.MessageResponse, error) {
+			switch message.ChannelExternalIdentifier {
+			case "!room:example.org", "!dm:example.org":
+			default:
+				t.Errorf("unexpected message to %s", message.ChannelExternalIdentifier)
+			}
+			return &matrixmessenger.MessageResponse{ExternalIdentifier: "$" + message.ChannelExternalIdentifier}, nil
+		},
+	)
+
+	err = s.getNextShifts(ctx, testRecordedAt.Add(-time.Minute*15))
+	if err != nil {
+		t.Fatalf("getNextShifts() error = %v", err)
+	}
+	if webhookCalls.Load() == 0 {
+		t.Error("failing webhook was not called")
+	}
+}
+
+func TestNotifySinksRetriesOnlyFailedSinks(t *testing.T) {
+	s, messenger := newTestService(t, newTestEngelsystem())
+	ctx := context.Background()
+
+	webhookCalls := atomic.Int64{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if webhookCalls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(webhook.Close)
+
+	sinks, err := sink.New(&sink.Config{Sinks: []sink.SinkConfig{
+		{Name: sink.MainName, Type: sink.TypeMatrix, RoomID: "!room:example.org"},
+		{Name: "flaky", Type: sink.TypeWebhook, URL: webhook.URL},
+	}}, messenger)
+	if err != nil {
+		t.Fatalf("sink.New() error = %v", err)
+	}
+	s.sinks = sinks
+
+	diffs, err := s.computeDiffs(ctx, testRecordedAt.Add(-time.Minute*15))
+	if err != nil {
+		t.Fatalf("computeDiffs() error = %v", err)
+	}
+
+	messenger.EXPECT().SendMessage(gomock.Any(), gomock.Any()).
+		Return(&matrixmessenger.MessageResponse{ExternalIdentifier: "$event"}, nil)
+
+	err = s.notifySinks(ctx, diffs)
+	if err == nil {
+		t.Fatal("notifySinks() should fail for the flaky webhook")
+	}
+
+	err = s.notifySinks(ctx, diffs)
+	if err != nil {
+		t.Fatalf("notifySinks() error = %v", err)
+	}
+	if webhookCalls.Load() != 2 {
+		t.Errorf("webhook called %d times, want 2", webhookCalls.Load())
+	}
+}
@@ -0,0 +1,207 @@
+package shiftnotifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+	"github.com/Cubicroots-Playground/trollinfo/internal/sink"
+	"github.com/Cubicroots-Playground/trollinfo/internal/store"
+)
+
+// sinkTimeout limits how long a single sink may take to deliver.
+const sinkTimeout = time.Second * 30
+
+// sinkDeliveries remembers what was sent to which sink to not send the same
+// notification twice, e.g. when the notifier retries.
+type sinkDeliveries struct {
+	mutex sync.Mutex
+	// bodies hold the last body sent per reference time and sink name.
+	bodies map[time.Time]map[string]string
+}
+
+func newSinkDeliveries() *sinkDeliveries {
+	return &sinkDeliveries{
+		bodies: make(map[time.Time]map[string]string),
+	}
+}
+
+func (deliveries *sinkDeliveries) isDelivered(refTime time.Time, sinkName, body string) bool {
+	deliveries.mutex.Lock()
+	defer deliveries.mutex.Unlock()
+
+	return deliveries.bodies[refTime][sinkName] == body
+}
+
+func (deliveries *sinkDeliveries) delivered(refTime time.Time, sinkName, body string) {
+	deliveries.mutex.Lock()
+	defer deliveries.mutex.Unlock()
+
+	for knownRefTime := range deliveries.bodies {
+		if time.Since(knownRefTime) > time.Hour*24 {
+			delete(deliveries.bodies, knownRefTime)
+		}
+	}
+
+	if deliveries.bodies[refTime] == nil {
+		deliveries.bodies[refTime] = make(map[string]string)
+	}
+	deliveries.bodies[refTime][sinkName] = body
+}
+
+// notifySinks sends the diffs of the routed locations to every sink. Sinks
+// are independent, a failing sink does not affect the others. The errors of
+// all failed sinks are returned.
+func (service *service) notifySinks(ctx context.Context, diffs *shiftDiffs) error {
+	return service.publishToSinks(ctx, diffs, false)
+}
+
+// retrySinks notifies the sinks again with the same diffs until all succeeded
+// or the context is done. Sinks that succeeded are not notified again.
+func (service *service) retrySinks(ctx context.Context, diffs *shiftDiffs, err error) {
+	delay := notifyRetryDelay
+	for err != nil {
+		select {
+		case <-ctx.Done():
+			slog.Error("failed to notify sinks, giving up", "error", err.Error())
+			return
+		case <-time.After(delay):
+			slog.Warn("failed to notify sinks, retrying", "error", err.Error())
+		}
+
+		err = service.notifySinks(ctx, diffs)
+		delay *= 2
+	}
+}
+
+// editSentMessages updates messages already sent for the reference time of
+// the diffs if their content changed. Nothing new is sent.
+func (service *service) editSentMessages(ctx context.Context, diffs *shiftDiffs) error {
+	return service.publishToSinks(ctx, diffs, true)
+}
+
+func (service *service) publishToSinks(ctx context.Context, diffs *shiftDiffs, onlyEdits bool) error {
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(service.sinks))
+	for i, s := range service.sinks {
+		editor, isEditor := s.(sink.Editor)
+		if onlyEdits && !isEditor {
+			continue
+		}
+
+		routed, hasContent := routeDiffs(s, diffs)
+		msg, msgFormatted := service.diffToMessage(routed)
+		message := &sink.Message{
+			Title: "Troll changes for " + diffs.ShiftStart.
+				In(defaultTimeZone()).
+				Format("Mon, 15:04"),
+			Body:     msg,
+			BodyHTML: msgFormatted,
+			Data:     toAPIDiff(routed),
+		}
+
+		if isEditor {
+			previous, err := service.store.LatestNotification(ctx, s.Name(), diffs.ReferenceTime)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				slog.Error("failed to load previous notification", "sink", s.Name(), "error", err.Error())
+			}
+			// A sent message is edited even if nobody changes anymore.
+			if (!hasContent && previous == nil) || (previous != nil && previous.Body == msg) || (onlyEdits && previous == nil) {
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = service.publishEditable(ctx, editor, routed, previous, message)
+			}()
+			continue
+		}
+
+		if !hasContent || service.sinkDeliveries.isDelivered(diffs.ReferenceTime, s.Name(), msg) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sendCtx, cancel := context.WithTimeout(ctx, sinkTimeout)
+			defer cancel()
+
+			err := s.Send(sendCtx, message)
+			if err != nil {
+				slog.Error("failed to notify sink", "sink", s.Name(), "error", err.Error())
+				errs[i] = fmt.Errorf("sink %s: %w", s.Name(), err)
+				return
+			}
+
+			service.sinkDeliveries.delivered(diffs.ReferenceTime, s.Name(), msg)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// routeDiffs returns the diffs of the locations handled by the sink and
+// whether anybody arrives or leaves in them.
+func routeDiffs(s sink.Sink, diffs *shiftDiffs) (*shiftDiffs, bool) {
+	routed := &shiftDiffs{
+		DiffsInLocations: map[string]shiftdiff.Diff{},
+		ReferenceTime:    diffs.ReferenceTime,
+		ShiftStart:       diffs.ShiftStart,
+		Stale:            diffs.Stale,
+	}
+	hasContent := false
+	for location, diff := range diffs.DiffsInLocations {
+		if !s.HandlesLocation(location) {
+			continue
+		}
+		routed.DiffsInLocations[location] = diff
+		hasContent = hasContent || len(diff.UsersArriving) > 0 || len(diff.UsersLeaving) > 0
+	}
+
+	return routed, hasContent
+}
+
+// publishEditable sends the message for the diffs or edits the previous one
+// for the same reference time instead of adding a near-duplicate.
+func (service *service) publishEditable(ctx context.Context, editor sink.Editor, diffs *shiftDiffs, previous *store.Notification, message *sink.Message) error {
+	sendCtx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+
+	var eventID string
+	var err error
+	if previous != nil {
+		eventID = previous.EventID
+		err = editor.Edit(sendCtx, eventID, message)
+	} else {
+		eventID, err = editor.SendEditable(sendCtx, message)
+	}
+	if err != nil {
+		slog.Error("failed to notify sink", "sink", editor.Name(), "error", err.Error())
+		return fmt.Errorf("sink %s: %w", editor.Name(), err)
+	}
+
+	err = service.store.SaveNotification(ctx, &store.Notification{
+		ReferenceTime: diffs.ReferenceTime,
+		Sink:          editor.Name(),
+		EventID:       eventID,
+		SentAt:        time.Now(),
+		Body:          message.Body,
+	})
+	if err != nil {
+		slog.Error("failed to store notification", "sink", editor.Name(), "error", err.Error())
+	}
+
+	if editor.Name() == sink.MainName {
+		service.requestCheckIns(ctx, diffs, eventID, previous == nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package shiftnotifier
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// diffState holds the latest diffs shared by the jobs, the matrix listener
+// and the HTTP handlers. Diffs are never modified after being stored, changes
+// swap in a new snapshot.
+type diffState struct {
+	current atomic.Pointer[shiftDiffs]
+	// updateMutex serializes writers, readers do not need to lock.
+	updateMutex sync.Mutex
+
+	subscribersMutex sync.Mutex
+	subscribers      map[chan *shiftDiffs]bool
+}
+
+func newDiffState() *diffState {
+	return &diffState{
+		subscribers: make(map[chan *shiftDiffs]bool),
+	}
+}
+
+// Load returns the latest diffs, nil if none were computed yet.
+func (state *diffState) Load() *shiftDiffs {
+	return state.current.Load()
+}
+
+// Store replaces the latest diffs and passes them to the subscribers.
+func (state *diffState) Store(diffs *shiftDiffs) {
+	state.updateMutex.Lock()
+	defer state.updateMutex.Unlock()
+
+	state.current.Store(diffs)
+	state.publish(diffs)
+}
+
+// Update replaces the latest diffs with the result of modify. Modify must
+// return a copy instead of changing the diffs passed.
+func (state *diffState) Update(modify func(*shiftDiffs) *shiftDiffs) {
+	state.updateMutex.Lock()
+	defer state.updateMutex.Unlock()
+
+	diffs := modify(state.current.Load())
+	state.current.Store(diffs)
+	state.publish(diffs)
+}
+
+// subscribe returns a channel receiving new diffs and a function to
+// unsubscribe. Slow subscribers only receive the latest diffs.
+func (state *diffState) subscribe() (chan *shiftDiffs, func()) {
+	updates := make(chan *shiftDiffs, 1)
+
+	state.subscribersMutex.Lock()
+	state.subscribers[updates] = true
+	state.subscribersMutex.Unlock()
+
+	return updates, func() {
+		state.subscribersMutex.Lock()
+		delete(state.subscribers, updates)
+		state.subscribersMutex.Unlock()
+	}
+}
+
+func (state *diffState) publish(diffs *shiftDiffs) {
+	state.subscribersMutex.Lock()
+	defer state.subscribersMutex.Unlock()
+
+	for updates := range state.subscribers {
+		// Replace a not yet consumed update.
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- diffs
+	}
+}
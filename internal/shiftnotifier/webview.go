@@ -2,12 +2,9 @@ package shiftnotifier
 
 import (
 	"encoding/json"
-	"errors"
 	"log/slog"
 	"net/http"
-	"strings"
 	"text/template"
-	"time"
 
 	_ "embed"
 )
@@ -15,46 +12,42 @@ import (
 //go:embed template/landscape.html
 var landscapeTemplate string
 
-func (service *service) requireToken(r *http.Request) error {
-	t := r.URL.Query().Get("token")
-	if strings.TrimSpace(t) != service.config.Token {
-		return errors.New("invalid auth")
-	}
-	return nil
-}
-
+// serveJSONData serves the internal diffs, deprecated in favor of /api/v1/diff.
 func (service *service) serveJSONData(w http.ResponseWriter, r *http.Request) {
-	err := service.requireToken(r)
+	_, err := service.requireToken(r, ScopeDisplayOnly)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		_, _ = w.Write([]byte("unauthorized"))
 		return
 	}
 
-	data, err := json.Marshal(service.latestDiffs)
+	data, err := json.Marshal(service.latestDiffs.Load())
 	if err != nil {
 		slog.Error("failed marshaling data", "error", err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		_, _ = w.Write([]byte("internal server error"))
 		return
 	}
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</api/v1/diff>; rel="successor-version"`)
 	_, _ = w.Write(data)
 }
 
 func (service *service) serveHumanPortrait(w http.ResponseWriter, r *http.Request) {
-	err := service.requireToken(r)
+	_, err := service.requireToken(r, ScopeDisplayOnly)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		_, _ = w.Write([]byte("unauthorized"))
 		return
 	}
 
-	if service.latestDiffs == nil {
+	diffs := service.latestDiffs.Load()
+	if diffs == nil {
 		_, _ = w.Write([]byte("no data"))
 		return
 	}
 
-	_, html := service.diffToMessage(service.latestDiffs)
+	_, html := service.diffToMessage(diffs)
 
 	if refreshSeconds := r.URL.Query().Get("refresh_seconds"); refreshSeconds != "" {
 		html = `<meta http-equiv="refresh" content="` + refreshSeconds + `">` + html
@@ -74,26 +67,21 @@ body {
 }
 
 func (service *service) serveHumanLandscape(w http.ResponseWriter, r *http.Request) {
-	err := service.requireToken(r)
+	_, err := service.requireToken(r, ScopeDisplayOnly)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		_, _ = w.Write([]byte("unauthorized"))
 		return
 	}
 
-	if service.latestDiffs == nil {
+	diffs := service.latestDiffs.Load()
+	if diffs == nil {
 		_, _ = w.Write([]byte("no data"))
 		return
 	}
 
-	defaultTZ, err := time.LoadLocation("Europe/Berlin")
-	if err != nil {
-		defaultTZ = time.Local
-	}
-
-	timeStr := service.latestDiffs.ReferenceTime.
-		Add(service.config.NotifyBeforeShiftStart).
-		In(defaultTZ).
+	timeStr := diffs.ShiftStart.
+		In(defaultTimeZone()).
 		Format("Mon, 15:04")
 
 	tmpl, err := template.New("landscape").Parse(landscapeTemplate)
@@ -103,7 +91,7 @@ func (service *service) serveHumanLandscape(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	err = tmpl.Execute(w, map[string]any{
-		"data":            service.latestDiffs,
+		"data":            diffs,
 		"refresh_seconds": r.URL.Query().Get("refresh_seconds"),
 		"shift_time":      timeStr,
 	})
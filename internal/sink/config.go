@@ -0,0 +1,152 @@
+package sink
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+)
+
+// MainName is the name of the sink for the matrix room configured with
+// TROLLINFO_MATRIX_ROOM_ID. Check-ins happen in that room.
+const MainName = "main"
+
+// Types of sinks available.
+const (
+	TypeMatrix  = "matrix"
+	TypeWebhook = "webhook"
+	TypeNtfy    = "ntfy"
+	TypeSMTP    = "smtp"
+)
+
+// Config holds the configuration of all sinks.
+type Config struct {
+	Sinks []SinkConfig
+}
+
+// SinkConfig holds the configuration of a single sink. Only the fields of
+// the sink's type are used.
+type SinkConfig struct {
+	Name string
+	Type string
+	// Locations the sink receives notifications for, all if empty.
+	Locations []string
+
+	// URL of the webhook or the ntfy topic.
+	URL string
+	// Token is sent as bearer token to webhooks and ntfy.
+	Token string
+
+	// RoomID is the matrix room to send messages to.
+	RoomID string
+
+	// SMTPAddr is the host:port of the mail server.
+	SMTPAddr string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// ParseFromEnvironment parses the config from the environment. Sinks are
+// listed in TROLLINFO_SINKS and configured with variables prefixed by their
+// name, e.g. TROLLINFO_SINK_BAR_NORD_TYPE for the sink bar-nord. The main
+// matrix room is added as sink main unless disabled with
+// TROLLINFO_SINK_MAIN_ENABLED=false.
+func (c *Config) ParseFromEnvironment() {
+	c.Sinks = []SinkConfig{}
+
+	names := listFromEnvironment("TROLLINFO_SINKS")
+	mainRoomID := os.Getenv("TROLLINFO_MATRIX_ROOM_ID")
+	if mainRoomID != "" && !slices.Contains(names, MainName) {
+		names = append([]string{MainName}, names...)
+	}
+
+	for _, name := range names {
+		prefix := "TROLLINFO_SINK_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)) + "_"
+		if os.Getenv(prefix+"ENABLED") == "false" {
+			continue
+		}
+
+		sinkConfig := SinkConfig{
+			Name:      name,
+			Type:      os.Getenv(prefix + "TYPE"),
+			Locations: listFromEnvironment(prefix + "LOCATIONS"),
+			URL:       os.Getenv(prefix + "URL"),
+			Token:     os.Getenv(prefix + "TOKEN"),
+			RoomID:    os.Getenv(prefix + "ROOM_ID"),
+			SMTPAddr:  os.Getenv(prefix + "SMTP_ADDR"),
+			Username:  os.Getenv(prefix + "USERNAME"),
+			Password:  os.Getenv(prefix + "PASSWORD"),
+			From:      os.Getenv(prefix + "FROM"),
+			To:        listFromEnvironment(prefix + "TO"),
+		}
+		if name == MainName {
+			sinkConfig.Type = TypeMatrix
+			sinkConfig.RoomID = mainRoomID
+		}
+		c.Sinks = append(c.Sinks, sinkConfig)
+	}
+}
+
+func listFromEnvironment(name string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		list = append(list, strings.TrimSpace(item))
+	}
+
+	return list
+}
+
+// New assembles all configured sinks.
+func New(config *Config, messenger matrixmessenger.Messenger) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(config.Sinks))
+	for i := range config.Sinks {
+		sink, err := newSink(&config.Sinks[i], messenger)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", config.Sinks[i].Name, err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+func newSink(config *SinkConfig, messenger matrixmessenger.Messenger) (Sink, error) {
+	r := router{
+		name:      config.Name,
+		locations: config.Locations,
+	}
+
+	switch config.Type {
+	case TypeMatrix:
+		if config.RoomID == "" {
+			return nil, fmt.Errorf("%w: room ID missing", ErrMissingConfig)
+		}
+		return newMatrix(r, messenger, config.RoomID), nil
+	case TypeWebhook:
+		if config.URL == "" {
+			return nil, fmt.Errorf("%w: URL missing", ErrMissingConfig)
+		}
+		return newWebhook(r, config.URL, config.Token), nil
+	case TypeNtfy:
+		if config.URL == "" {
+			return nil, fmt.Errorf("%w: URL missing", ErrMissingConfig)
+		}
+		return newNtfy(r, config.URL, config.Token), nil
+	case TypeSMTP:
+		if config.SMTPAddr == "" || config.From == "" || len(config.To) == 0 {
+			return nil, fmt.Errorf("%w: SMTP address, sender or recipients missing", ErrMissingConfig)
+		}
+		return newSMTP(r, config), nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownType, config.Type)
+}
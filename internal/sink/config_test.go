@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseFromEnvironment(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantNames []string
+	}{
+		{
+			name:      "main room only",
+			env:       map[string]string{"TROLLINFO_MATRIX_ROOM_ID": "!main:example.org"},
+			wantNames: []string{MainName},
+		},
+		{
+			name: "main room first",
+			env: map[string]string{
+				"TROLLINFO_MATRIX_ROOM_ID":     "!main:example.org",
+				"TROLLINFO_SINKS":              "bar-nord",
+				"TROLLINFO_SINK_BAR_NORD_TYPE": TypeWebhook,
+			},
+			wantNames: []string{MainName, "bar-nord"},
+		},
+		{
+			name: "main room disabled",
+			env: map[string]string{
+				"TROLLINFO_MATRIX_ROOM_ID":    "!main:example.org",
+				"TROLLINFO_SINKS":             "bar-nord",
+				"TROLLINFO_SINK_MAIN_ENABLED": "false",
+			},
+			wantNames: []string{"bar-nord"},
+		},
+		{
+			name: "main room routed",
+			env: map[string]string{
+				"TROLLINFO_MATRIX_ROOM_ID":      "!main:example.org",
+				"TROLLINFO_SINKS":               "bar-nord, main",
+				"TROLLINFO_SINK_MAIN_LOCATIONS": "Bar Nord",
+			},
+			wantNames: []string{"bar-nord", MainName},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			config := Config{}
+			config.ParseFromEnvironment()
+
+			names := []string{}
+			for _, sinkConfig := range config.Sinks {
+				names = append(names, sinkConfig.Name)
+				if sinkConfig.Name == MainName && (sinkConfig.Type != TypeMatrix || sinkConfig.RoomID != "!main:example.org") {
+					t.Errorf("main sink is %s to %q, want matrix room", sinkConfig.Type, sinkConfig.RoomID)
+				}
+			}
+			if !slices.Equal(names, tt.wantNames) {
+				t.Errorf("sinks = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
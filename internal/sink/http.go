@@ -0,0 +1,105 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpTimeout limits requests of HTTP based sinks.
+const httpTimeout = time.Second * 10
+
+// webhookSink posts notifications as JSON to a URL.
+type webhookSink struct {
+	router
+	client *http.Client
+	url    string
+	token  string
+}
+
+type webhookPayload struct {
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	BodyHTML string `json:"body_html"`
+	Data     any    `json:"data,omitempty"`
+}
+
+func newWebhook(r router, url, token string) *webhookSink {
+	return &webhookSink{
+		router: r,
+		client: &http.Client{Timeout: httpTimeout},
+		url:    url,
+		token:  token,
+	}
+}
+
+func (sink *webhookSink) Send(ctx context.Context, message *Message) error {
+	body, err := json.Marshal(&webhookPayload{
+		Title:    message.Title,
+		Body:     message.Body,
+		BodyHTML: message.BodyHTML,
+		Data:     message.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return doRequest(sink.client, req, sink.token)
+}
+
+// ntfySink publishes notifications to an ntfy compatible topic.
+type ntfySink struct {
+	router
+	client *http.Client
+	url    string
+	token  string
+}
+
+func newNtfy(r router, url, token string) *ntfySink {
+	return &ntfySink{
+		router: r,
+		client: &http.Client{Timeout: httpTimeout},
+		url:    url,
+		token:  token,
+	}
+}
+
+func (sink *ntfySink) Send(ctx context.Context, message *Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.url, bytes.NewBufferString(message.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", message.Title)
+	req.Header.Set("Tags", "troll")
+
+	return doRequest(sink.client, req, sink.token)
+}
+
+func doRequest(client *http.Client, req *http.Request, token string) error {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
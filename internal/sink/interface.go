@@ -0,0 +1,65 @@
+package sink
+
+import (
+	"context"
+	"errors"
+)
+
+// Sink delivers notifications to a destination like a chat room or a push
+// service.
+type Sink interface {
+	// Name identifies the sink in logs.
+	Name() string
+	// HandlesLocation returns true if notifications about the location are
+	// routed to the sink.
+	HandlesLocation(location string) bool
+	Send(ctx context.Context, message *Message) error
+}
+
+// Editor is implemented by sinks that can update messages they sent, e.g.
+// when the trolls of a shift change.
+type Editor interface {
+	Sink
+	// SendEditable sends the message and returns its ID for later edits.
+	SendEditable(ctx context.Context, message *Message) (string, error)
+	Edit(ctx context.Context, messageID string, message *Message) error
+}
+
+// Message holds a notification in the formats supported by the sinks.
+type Message struct {
+	Title    string
+	Body     string
+	BodyHTML string
+	// Data is sent as JSON by sinks supporting structured payloads.
+	Data any
+}
+
+// Errors returned by sinks.
+var (
+	ErrUnknownType   = errors.New("unknown sink type")
+	ErrMissingConfig = errors.New("sink config incomplete")
+)
+
+// router implements the location routing shared by all sinks.
+type router struct {
+	name      string
+	locations []string
+}
+
+func (router *router) Name() string {
+	return router.name
+}
+
+func (router *router) HandlesLocation(location string) bool {
+	if len(router.locations) == 0 {
+		return true
+	}
+
+	for _, l := range router.locations {
+		if l == location {
+			return true
+		}
+	}
+
+	return false
+}
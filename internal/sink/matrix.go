@@ -0,0 +1,41 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/matrixmessenger"
+)
+
+// matrixSink sends notifications to a matrix room.
+type matrixSink struct {
+	router
+	messenger matrixmessenger.Messenger
+	roomID    string
+}
+
+func newMatrix(r router, messenger matrixmessenger.Messenger, roomID string) *matrixSink {
+	return &matrixSink{
+		router:    r,
+		messenger: messenger,
+		roomID:    roomID,
+	}
+}
+
+func (sink *matrixSink) Send(ctx context.Context, message *Message) error {
+	_, err := sink.SendEditable(ctx, message)
+	return err
+}
+
+func (sink *matrixSink) SendEditable(ctx context.Context, message *Message) (string, error) {
+	resp, err := sink.messenger.SendMessage(ctx, matrixmessenger.HTMLMessage(message.Body, message.BodyHTML, sink.roomID))
+	if err != nil {
+		return "", err
+	}
+
+	return resp.ExternalIdentifier, nil
+}
+
+func (sink *matrixSink) Edit(ctx context.Context, messageID string, message *Message) error {
+	_, err := sink.messenger.EditMessage(ctx, messageID, matrixmessenger.HTMLMessage(message.Body, message.BodyHTML, sink.roomID))
+	return err
+}
@@ -0,0 +1,59 @@
+package sink
+
+import (
+	"context"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// smtpSink sends notifications as plain text email.
+type smtpSink struct {
+	router
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func newSMTP(r router, config *SinkConfig) *smtpSink {
+	return &smtpSink{
+		router:   r,
+		addr:     config.SMTPAddr,
+		username: config.Username,
+		password: config.Password,
+		from:     config.From,
+		to:       config.To,
+	}
+}
+
+func (sink *smtpSink) Send(ctx context.Context, message *Message) error {
+	var auth smtp.Auth
+	if sink.username != "" {
+		host, _, err := net.SplitHostPort(sink.addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", sink.username, sink.password, host)
+	}
+
+	mail := strings.Builder{}
+	mail.WriteString("From: " + sink.from + "\r\n")
+	mail.WriteString("To: " + strings.Join(sink.to, ", ") + "\r\n")
+	mail.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.Title) + "\r\n")
+	mail.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	mail.WriteString("MIME-Version: 1.0\r\n")
+	mail.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	mail.WriteString("\r\n")
+	mail.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	// net/smtp does not support contexts, at least do not start sending late.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return smtp.SendMail(sink.addr, auth, sink.from, sink.to, []byte(mail.String()))
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets used in the bolt database. Keys are big endian unix nanoseconds so
+// they sort by time, unless noted otherwise.
+var (
+	bucketShiftSnapshots = []byte("shift_snapshots") // Nested bucket per location.
+	bucketDiffSnapshots  = []byte("diff_snapshots")
+	bucketNotifications  = []byte("notifications") // Nested buckets per sink and reference time.
+	bucketEscalations    = []byte("escalations")   // Keyed by shift and angel type.
+	bucketUserMappings   = []byte("user_mappings") // Keyed by user ID or nickname.
+)
+
+type boltStore struct {
+	db        *bolt.DB
+	retention time.Duration
+}
+
+// NewBolt assembles a store persisting to a bolt database at the given path,
+// snapshots and notifications are kept for the retention or forever if not
+// positive.
+func NewBolt(path string, retention time.Duration) (Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second * 5})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{bucketShiftSnapshots, bucketDiffSnapshots, bucketNotifications, bucketEscalations, bucketUserMappings} {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &boltStore{
+		db:        db,
+		retention: retention,
+	}, nil
+}
+
+func (store *boltStore) SaveShiftSnapshot(_ context.Context, snapshot *ShiftSnapshot) error {
+	return store.update(timeKey(snapshot.FetchedAt), snapshot.withoutPersonalData(), store.cutoff(snapshot.FetchedAt), bucketShiftSnapshots, []byte(strconv.FormatInt(snapshot.LocationID, 10)))
+}
+
+func (store *boltStore) LatestShiftSnapshot(_ context.Context, locationID int64) (*ShiftSnapshot, error) {
+	snapshot := &ShiftSnapshot{}
+	err := store.last(snapshot, bucketShiftSnapshots, []byte(strconv.FormatInt(locationID, 10)))
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (store *boltStore) SaveDiffSnapshot(_ context.Context, snapshot *DiffSnapshot) error {
+	return store.update(timeKey(snapshot.ComputedAt), snapshot, store.cutoff(snapshot.ComputedAt), bucketDiffSnapshots)
+}
+
+func (store *boltStore) LatestDiffSnapshot(_ context.Context) (*DiffSnapshot, error) {
+	snapshot := &DiffSnapshot{}
+	err := store.last(snapshot, bucketDiffSnapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (store *boltStore) SaveNotification(_ context.Context, notification *Notification) error {
+	err := store.put(timeKey(notification.SentAt), notification, bucketNotifications, []byte(notification.Sink), timeKey(notification.ReferenceTime))
+	if err != nil {
+		return err
+	}
+
+	cutoff := store.cutoff(notification.SentAt)
+	if cutoff == nil {
+		return nil
+	}
+
+	// Remove the buckets of past reference times of all sinks.
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketNotifications).ForEachBucket(func(sinkName []byte) error {
+			sinkBucket := tx.Bucket(bucketNotifications).Bucket(sinkName)
+			expired := [][]byte{}
+			err := sinkBucket.ForEachBucket(func(refTime []byte) error {
+				if bytes.Compare(refTime, cutoff) < 0 {
+					expired = append(expired, bytes.Clone(refTime))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, refTime := range expired {
+				err = sinkBucket.DeleteBucket(refTime)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func (store *boltStore) LatestNotification(_ context.Context, sinkName string, referenceTime time.Time) (*Notification, error) {
+	notification := &Notification{}
+	err := store.last(notification, bucketNotifications, []byte(sinkName), timeKey(referenceTime))
+	if err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+func (store *boltStore) SaveEscalation(_ context.Context, escalation *Escalation) error {
+	return store.put([]byte(escalationKey(escalation.ShiftID, escalation.AngelType)), escalation, bucketEscalations)
+}
+
+func (store *boltStore) GetEscalation(_ context.Context, shiftID int64, angelType string) (*Escalation, error) {
+	escalation := &Escalation{}
+	err := store.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(bucketEscalations).Get([]byte(escalationKey(shiftID, angelType)))
+		if raw == nil {
+			return ErrNotFound
+		}
+
+		return json.Unmarshal(raw, escalation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return escalation, nil
+}
+
+func (store *boltStore) DeleteEscalationsBefore(_ context.Context, shiftStart time.Time) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketEscalations)
+
+		// Deleting while iterating skips keys, collect them first.
+		keys := [][]byte{}
+		err := bucket.ForEach(func(k, v []byte) error {
+			escalation := &Escalation{}
+			err := json.Unmarshal(v, escalation)
+			if err != nil {
+				return err
+			}
+			if escalation.ShiftStartsAt.Before(shiftStart) {
+				keys = append(keys, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			err = bucket.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (store *boltStore) SaveUserMapping(_ context.Context, mapping *UserMapping) error {
+	keys := userMappingKeys(mapping.EngelsystemUserID, mapping.Nickname)
+	if len(keys) == 0 {
+		return ErrNotFound
+	}
+
+	return store.put([]byte(keys[0]), mapping, bucketUserMappings)
+}
+
+func (store *boltStore) GetUserMapping(_ context.Context, userID int64, nickname string) (*UserMapping, error) {
+	mapping := &UserMapping{}
+	err := store.db.View(func(tx *bolt.Tx) error {
+		for _, key := range userMappingKeys(userID, nickname) {
+			raw := tx.Bucket(bucketUserMappings).Get([]byte(key))
+			if raw != nil {
+				return json.Unmarshal(raw, mapping)
+			}
+		}
+
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+func (store *boltStore) Close() error {
+	return store.db.Close()
+}
+
+// put stores value with the given key in the bucket found by path, nested
+// buckets are created as needed.
+func (store *boltStore) put(key []byte, value any, path ...[]byte) error {
+	return store.update(key, value, nil, path...)
+}
+
+// update stores value like put, removing values with keys before pruneBefore
+// if set.
+func (store *boltStore) update(key []byte, value any, pruneBefore []byte, path ...[]byte) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(path[0])
+		for _, name := range path[1:] {
+			bucket, err = bucket.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		if pruneBefore != nil {
+			// Deleting while iterating skips keys, collect them first.
+			keys := [][]byte{}
+			cursor := bucket.Cursor()
+			for k, v := cursor.First(); k != nil && bytes.Compare(k, pruneBefore) < 0; k, v = cursor.Next() {
+				if v != nil {
+					keys = append(keys, bytes.Clone(k))
+				}
+			}
+			for _, k := range keys {
+				err = bucket.Delete(k)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return bucket.Put(key, raw)
+	})
+}
+
+// last reads the value with the highest key from the bucket found by path.
+func (store *boltStore) last(value any, path ...[]byte) error {
+	return store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(path[0])
+		for _, name := range path[1:] {
+			if bucket == nil {
+				break
+			}
+			bucket = bucket.Bucket(name)
+		}
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		_, raw := bucket.Cursor().Last()
+		if raw == nil {
+			return ErrNotFound
+		}
+
+		return json.Unmarshal(raw, value)
+	})
+}
+
+// cutoff returns the key before which data saved at now is past the
+// retention, nil if everything is kept.
+func (store *boltStore) cutoff(now time.Time) []byte {
+	if store.retention <= 0 {
+		return nil
+	}
+
+	return timeKey(now.Add(-store.retention))
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Config holds the configuration for the store.
+type Config struct {
+	// Path of the bolt database, an in-memory store is used if empty.
+	Path string
+	// Retention defines how long snapshots and notifications are kept,
+	// everything is kept if not positive.
+	Retention time.Duration
+}
+
+// ParseFromEnvironment parses the config from the environment.
+func (c *Config) ParseFromEnvironment() {
+	c.Path = os.Getenv("TROLLINFO_STORE_PATH")
+	c.Retention = time.Hour * 24 * 7
+	if retention := os.Getenv("TROLLINFO_STORE_RETENTION"); retention != "" {
+		parsed, err := time.ParseDuration(retention)
+		if err != nil {
+			slog.Warn("invalid store retention, using default", "value", retention)
+		} else {
+			c.Retention = parsed
+		}
+	}
+}
+
+// New assembles the store selected by the config.
+func New(config *Config) (Store, error) {
+	if config.Path == "" {
+		return NewMemory(config.Retention), nil
+	}
+
+	return NewBolt(config.Path, config.Retention)
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	"github.com/Cubicroots-Playground/trollinfo/internal/shiftdiff"
+)
+
+// Store persists the state of the notifier across restarts. Snapshots and
+// notifications older than the retention are removed when saving new ones.
+type Store interface {
+	// SaveShiftSnapshot adds a snapshot of the location. Contact data and
+	// real names of users are not persisted.
+	SaveShiftSnapshot(context.Context, *ShiftSnapshot) error
+	// LatestShiftSnapshot returns the most recent snapshot of a location.
+	LatestShiftSnapshot(ctx context.Context, locationID int64) (*ShiftSnapshot, error)
+
+	SaveDiffSnapshot(context.Context, *DiffSnapshot) error
+	// LatestDiffSnapshot returns the most recently computed diffs.
+	LatestDiffSnapshot(context.Context) (*DiffSnapshot, error)
+
+	SaveNotification(context.Context, *Notification) error
+	// LatestNotification returns the most recent notification sent to the
+	// sink for the given reference time.
+	LatestNotification(ctx context.Context, sinkName string, referenceTime time.Time) (*Notification, error)
+
+	// SaveEscalation creates or replaces the escalation for its shift and
+	// angel type.
+	SaveEscalation(context.Context, *Escalation) error
+	GetEscalation(ctx context.Context, shiftID int64, angelType string) (*Escalation, error)
+	// DeleteEscalationsBefore removes the escalations of shifts starting
+	// before the given time.
+	DeleteEscalationsBefore(ctx context.Context, shiftStart time.Time) error
+
+	// SaveUserMapping creates or replaces the mapping for its Engelsystem
+	// user ID, or nickname if the ID is unknown.
+	SaveUserMapping(context.Context, *UserMapping) error
+	// GetUserMapping returns the mapping for the user ID, falling back to
+	// the nickname.
+	GetUserMapping(ctx context.Context, userID int64, nickname string) (*UserMapping, error)
+
+	Close() error
+}
+
+// Errors returned by the store.
+var (
+	ErrNotFound = errors.New("not found")
+)
+
+// ShiftSnapshot holds the shifts fetched for a location.
+type ShiftSnapshot struct {
+	LocationID   int64
+	LocationName string
+	FetchedAt    time.Time
+	Shifts       []angelapi.Shift
+}
+
+// DiffSnapshot holds diffs computed for all locations.
+type DiffSnapshot struct {
+	DiffsInLocations map[string]shiftdiff.Diff
+	ReferenceTime    time.Time
+	ComputedAt       time.Time
+	Stale            bool
+}
+
+// Notification holds a message sent to a sink supporting edits. Edits of a
+// message are saved as notifications with the same event ID.
+type Notification struct {
+	ReferenceTime time.Time
+	// Sink is the name of the sink the message was sent to.
+	Sink    string
+	EventID string
+	SentAt  time.Time
+	// Body holds the plain text of the message to detect changes.
+	Body string
+}
+
+// Escalation holds the state of an understaffing alert for an angel type in a
+// shift.
+type Escalation struct {
+	ShiftID       int64
+	AngelType     string
+	LocationID    int64
+	ShiftStartsAt time.Time
+	// Level is the lead time of the most urgent alert sent.
+	Level     time.Duration
+	Open      int64
+	EventID   string
+	AlertedAt time.Time
+	// ResolvedAt is set once the gap was filled.
+	ResolvedAt time.Time
+}
+
+// UserMapping maps an Engelsystem user to a matrix user.
+type UserMapping struct {
+	EngelsystemUserID int64
+	Nickname          string
+	MatrixUserID      string
+	// DirectRoomID is the room used for direct messages to the user.
+	DirectRoomID string
+	// LastReminderFor holds the start of the shift the user was last
+	// reminded about.
+	LastReminderFor time.Time
+}
+
+// withoutPersonalData returns a copy of the snapshot with users reduced to
+// what is needed to compare shifts.
+func (snapshot *ShiftSnapshot) withoutPersonalData() *ShiftSnapshot {
+	stripped := *snapshot
+	stripped.Shifts = make([]angelapi.Shift, 0, len(snapshot.Shifts))
+	for _, shift := range snapshot.Shifts {
+		entries := make([]angelapi.ShiftEntry, 0, len(shift.Entries))
+		for _, entry := range shift.Entries {
+			users := make([]angelapi.User, 0, len(entry.Users))
+			for _, user := range entry.Users {
+				users = append(users, angelapi.User{
+					ID:       user.ID,
+					NickName: user.NickName,
+					Pronoun:  user.Pronoun,
+				})
+			}
+			entry.Users = users
+			entries = append(entries, entry)
+		}
+		shift.Entries = entries
+		stripped.Shifts = append(stripped.Shifts, shift)
+	}
+
+	return &stripped
+}
+
+func escalationKey(shiftID int64, angelType string) string {
+	return strconv.FormatInt(shiftID, 10) + "/" + angelType
+}
+
+func userMappingKeys(userID int64, nickname string) []string {
+	keys := []string{}
+	if userID != 0 {
+		keys = append(keys, "id/"+strconv.FormatInt(userID, 10))
+	}
+	if nickname != "" {
+		keys = append(keys, "nickname/"+strings.ToLower(nickname))
+	}
+
+	return keys
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+type memoryStore struct {
+	retention      time.Duration
+	shiftSnapshots map[int64][]ShiftSnapshot
+	diffSnapshots  []DiffSnapshot
+	notifications  []Notification
+	escalations    map[string]Escalation
+	userMappings   map[string]UserMapping
+	mutex          sync.Mutex
+}
+
+// NewMemory assembles a store keeping everything in memory for the given
+// retention, forever if not positive.
+func NewMemory(retention time.Duration) Store {
+	return &memoryStore{
+		retention:      retention,
+		shiftSnapshots: make(map[int64][]ShiftSnapshot),
+		escalations:    make(map[string]Escalation),
+		userMappings:   make(map[string]UserMapping),
+	}
+}
+
+func (store *memoryStore) SaveShiftSnapshot(_ context.Context, snapshot *ShiftSnapshot) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	snapshots := slices.DeleteFunc(store.shiftSnapshots[snapshot.LocationID], func(saved ShiftSnapshot) bool {
+		return store.expired(saved.FetchedAt, snapshot.FetchedAt)
+	})
+	store.shiftSnapshots[snapshot.LocationID] = append(snapshots, *snapshot.withoutPersonalData())
+	return nil
+}
+
+func (store *memoryStore) LatestShiftSnapshot(_ context.Context, locationID int64) (*ShiftSnapshot, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	snapshots := store.shiftSnapshots[locationID]
+	if len(snapshots) == 0 {
+		return nil, ErrNotFound
+	}
+
+	snapshot := snapshots[len(snapshots)-1]
+	return &snapshot, nil
+}
+
+func (store *memoryStore) SaveDiffSnapshot(_ context.Context, snapshot *DiffSnapshot) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.diffSnapshots = slices.DeleteFunc(store.diffSnapshots, func(saved DiffSnapshot) bool {
+		return store.expired(saved.ComputedAt, snapshot.ComputedAt)
+	})
+	store.diffSnapshots = append(store.diffSnapshots, *snapshot)
+	return nil
+}
+
+func (store *memoryStore) LatestDiffSnapshot(_ context.Context) (*DiffSnapshot, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if len(store.diffSnapshots) == 0 {
+		return nil, ErrNotFound
+	}
+
+	snapshot := store.diffSnapshots[len(store.diffSnapshots)-1]
+	return &snapshot, nil
+}
+
+func (store *memoryStore) SaveNotification(_ context.Context, notification *Notification) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.notifications = slices.DeleteFunc(store.notifications, func(saved Notification) bool {
+		return store.expired(saved.ReferenceTime, notification.SentAt)
+	})
+	store.notifications = append(store.notifications, *notification)
+	return nil
+}
+
+func (store *memoryStore) LatestNotification(_ context.Context, sinkName string, referenceTime time.Time) (*Notification, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for i := len(store.notifications) - 1; i >= 0; i-- {
+		if store.notifications[i].Sink == sinkName && store.notifications[i].ReferenceTime.Equal(referenceTime) {
+			notification := store.notifications[i]
+			return &notification, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (store *memoryStore) SaveEscalation(_ context.Context, escalation *Escalation) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.escalations[escalationKey(escalation.ShiftID, escalation.AngelType)] = *escalation
+	return nil
+}
+
+func (store *memoryStore) GetEscalation(_ context.Context, shiftID int64, angelType string) (*Escalation, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	escalation, ok := store.escalations[escalationKey(shiftID, angelType)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &escalation, nil
+}
+
+func (store *memoryStore) DeleteEscalationsBefore(_ context.Context, shiftStart time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	maps.DeleteFunc(store.escalations, func(_ string, escalation Escalation) bool {
+		return escalation.ShiftStartsAt.Before(shiftStart)
+	})
+	return nil
+}
+
+func (store *memoryStore) SaveUserMapping(_ context.Context, mapping *UserMapping) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	keys := userMappingKeys(mapping.EngelsystemUserID, mapping.Nickname)
+	if len(keys) == 0 {
+		return ErrNotFound
+	}
+
+	store.userMappings[keys[0]] = *mapping
+	return nil
+}
+
+func (store *memoryStore) GetUserMapping(_ context.Context, userID int64, nickname string) (*UserMapping, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, key := range userMappingKeys(userID, nickname) {
+		if mapping, ok := store.userMappings[key]; ok {
+			return &mapping, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (store *memoryStore) Close() error {
+	return nil
+}
+
+// expired reports whether data saved at the given time is past the retention
+// when saving new data at now.
+func (store *memoryStore) expired(savedAt, now time.Time) bool {
+	return store.retention > 0 && now.Sub(savedAt) > store.retention
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cubicroots-Playground/trollinfo/internal/angelapi"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestShiftSnapshots(t *testing.T) {
+	persistent, err := NewBolt(filepath.Join(t.TempDir(), "store.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewBolt() error = %v", err)
+	}
+	defer persistent.Close()
+
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{name: "memory", store: NewMemory(time.Hour)},
+		{name: "bolt", store: persistent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fetchedAt := time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC)
+
+			err := tt.store.SaveShiftSnapshot(ctx, &ShiftSnapshot{
+				LocationID: 1,
+				FetchedAt:  fetchedAt.Add(-time.Hour * 2),
+			})
+			if err != nil {
+				t.Fatalf("SaveShiftSnapshot() error = %v", err)
+			}
+
+			for i := 0; i < 3; i++ {
+				err := tt.store.SaveShiftSnapshot(ctx, &ShiftSnapshot{
+					LocationID: 1,
+					FetchedAt:  fetchedAt.Add(time.Minute * time.Duration(i)),
+					Shifts: []angelapi.Shift{{
+						ID: int64(i),
+						Entries: []angelapi.ShiftEntry{{
+							Users: []angelapi.User{{
+								ID:        1,
+								NickName:  "Troll 1",
+								FirstName: "First",
+								LastName:  "Last",
+								Contact:   angelapi.UserContact{DECT: "1234", Mobile: "+49123"},
+							}},
+						}},
+					}},
+				})
+				if err != nil {
+					t.Fatalf("SaveShiftSnapshot() error = %v", err)
+				}
+			}
+
+			snapshot, err := tt.store.LatestShiftSnapshot(ctx, 1)
+			if err != nil {
+				t.Fatalf("LatestShiftSnapshot() error = %v", err)
+			}
+			if snapshot.Shifts[0].ID != 2 {
+				t.Errorf("latest snapshot holds shift %d, want 2", snapshot.Shifts[0].ID)
+			}
+
+			user := snapshot.Shifts[0].Entries[0].Users[0]
+			want := angelapi.User{ID: 1, NickName: "Troll 1"}
+			if user != want {
+				t.Errorf("persisted user = %+v, want %+v", user, want)
+			}
+
+			_, err = tt.store.LatestShiftSnapshot(ctx, 2)
+			if err != ErrNotFound {
+				t.Errorf("LatestShiftSnapshot() of unknown location error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+
+	// The history within the retention is kept.
+	if n := len(tests[0].store.(*memoryStore).shiftSnapshots[1]); n != 3 {
+		t.Errorf("memory keeps %d snapshots, want 3", n)
+	}
+	err = persistent.(*boltStore).db.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket(bucketShiftSnapshots).Bucket([]byte("1")).Stats().KeyN; n != 3 {
+			t.Errorf("bolt keeps %d snapshots, want 3", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	persistent, err := NewBolt(filepath.Join(t.TempDir(), "store.db"), 0)
+	if err != nil {
+		t.Fatalf("NewBolt() error = %v", err)
+	}
+	defer persistent.Close()
+
+	for _, store := range []Store{NewMemory(0), persistent} {
+		ctx := context.Background()
+		referenceTime := time.Date(2024, 5, 31, 17, 45, 0, 0, time.UTC)
+
+		_, err := store.LatestDiffSnapshot(ctx)
+		if err != ErrNotFound {
+			t.Errorf("LatestDiffSnapshot() of empty store error = %v, want %v", err, ErrNotFound)
+		}
+
+		for i := 0; i < 3; i++ {
+			err := store.SaveDiffSnapshot(ctx, &DiffSnapshot{
+				ReferenceTime: referenceTime.Add(time.Hour * time.Duration(i)),
+				ComputedAt:    referenceTime.Add(time.Minute * time.Duration(i)),
+			})
+			if err != nil {
+				t.Fatalf("SaveDiffSnapshot() error = %v", err)
+			}
+		}
+
+		snapshot, err := store.LatestDiffSnapshot(ctx)
+		if err != nil {
+			t.Fatalf("LatestDiffSnapshot() error = %v", err)
+		}
+		if want := referenceTime.Add(time.Hour * 2); !snapshot.ReferenceTime.Equal(want) {
+			t.Errorf("latest diff snapshot is for %v, want %v", snapshot.ReferenceTime, want)
+		}
+	}
+}
+
+func TestDiffSnapshotsRetention(t *testing.T) {
+	persistent, err := NewBolt(filepath.Join(t.TempDir(), "store.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewBolt() error = %v", err)
+	}
+	defer persistent.Close()
+
+	memory := NewMemory(time.Hour)
+	computedAt := time.Date(2024, 5, 31, 17, 45, 0, 0, time.UTC)
+	for _, store := range []Store{memory, persistent} {
+		for _, offset := range []time.Duration{-time.Hour * 2, -time.Minute * 30, 0} {
+			err := store.SaveDiffSnapshot(context.Background(), &DiffSnapshot{
+				ReferenceTime: computedAt.Add(offset),
+				ComputedAt:    computedAt.Add(offset),
+			})
+			if err != nil {
+				t.Fatalf("SaveDiffSnapshot() error = %v", err)
+			}
+		}
+	}
+
+	if n := len(memory.(*memoryStore).diffSnapshots); n != 2 {
+		t.Errorf("memory keeps %d diff snapshots, want 2", n)
+	}
+	err = persistent.(*boltStore).db.View(func(tx *bolt.Tx) error {
+		if n := tx.Bucket(bucketDiffSnapshots).Stats().KeyN; n != 2 {
+			t.Errorf("bolt keeps %d diff snapshots, want 2", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotificationsRetention(t *testing.T) {
+	persistent, err := NewBolt(filepath.Join(t.TempDir(), "store.db"), time.Hour)
+	if err != nil {
+		t.Fatalf("NewBolt() error = %v", err)
+	}
+	defer persistent.Close()
+
+	for _, store := range []Store{NewMemory(time.Hour), persistent} {
+		ctx := context.Background()
+		old := time.Date(2024, 5, 31, 16, 0, 0, 0, time.UTC)
+		recent := old.Add(time.Hour * 2)
+
+		notifications := []*Notification{
+			{ReferenceTime: old, Sink: "main", EventID: "$old", SentAt: old},
+			{ReferenceTime: old, Sink: "bar", EventID: "$old-bar", SentAt: old},
+			{ReferenceTime: recent, Sink: "main", EventID: "$recent", SentAt: recent},
+		}
+		for _, notification := range notifications {
+			err := store.SaveNotification(ctx, notification)
+			if err != nil {
+				t.Fatalf("SaveNotification() error = %v", err)
+			}
+		}
+
+		for _, notification := range notifications[:2] {
+			_, err := store.LatestNotification(ctx, notification.Sink, old)
+			if err != ErrNotFound {
+				t.Errorf("LatestNotification() of %s past the retention error = %v, want %v", notification.Sink, err, ErrNotFound)
+			}
+		}
+
+		notification, err := store.LatestNotification(ctx, "main", recent)
+		if err != nil {
+			t.Fatalf("LatestNotification() error = %v", err)
+		}
+		if notification.EventID != "$recent" {
+			t.Errorf("notification event ID = %s, want $recent", notification.EventID)
+		}
+	}
+}
+
+func TestDeleteEscalationsBefore(t *testing.T) {
+	persistent, err := NewBolt(filepath.Join(t.TempDir(), "store.db"), 0)
+	if err != nil {
+		t.Fatalf("NewBolt() error = %v", err)
+	}
+	defer persistent.Close()
+
+	for _, store := range []Store{NewMemory(0), persistent} {
+		ctx := context.Background()
+		now := time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC)
+
+		for shiftID, startsAt := range map[int64]time.Time{1: now.Add(-time.Hour), 2: now, 3: now.Add(time.Hour)} {
+			err := store.SaveEscalation(ctx, &Escalation{ShiftID: shiftID, AngelType: "Runner", ShiftStartsAt: startsAt})
+			if err != nil {
+				t.Fatalf("SaveEscalation() error = %v", err)
+			}
+		}
+
+		err := store.DeleteEscalationsBefore(ctx, now)
+		if err != nil {
+			t.Fatalf("DeleteEscalationsBefore() error = %v", err)
+		}
+
+		_, err = store.GetEscalation(ctx, 1, "Runner")
+		if err != ErrNotFound {
+			t.Errorf("GetEscalation() of past shift error = %v, want %v", err, ErrNotFound)
+		}
+		for _, shiftID := range []int64{2, 3} {
+			_, err = store.GetEscalation(ctx, shiftID, "Runner")
+			if err != nil {
+				t.Errorf("GetEscalation() of shift %d error = %v", shiftID, err)
+			}
+		}
+	}
+}